@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/demosdemon/shop/internal/config"
+	"github.com/demosdemon/shop/pkg/pool"
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+type result struct {
+	StoreID       string                 `json:"store_id"`
+	OK            bool                   `json:"ok"`
+	Shop          *shopify.Shop          `json:"shop,omitempty"`
+	Scopes        []string               `json:"scopes,omitempty"`
+	MissingScopes []string               `json:"missing_scopes,omitempty"`
+	BucketSize    int                    `json:"bucket_size,omitempty"`
+	Condition     shopify.StoreCondition `json:"condition,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+}
+
+func main() {
+	storesFile := flag.String("stores", "./stores.jsonl", "path to store configuration file")
+	resources := flag.String("resources", strings.Join(shopify.DefaultResources, ","), "comma separated list of resources whose scopes are required")
+	apiVersion := flag.String("shopify-version", shopify.DefaultAPIVersion, "shopify API version")
+	format := flag.String("format", "table", "output format: table or json")
+	concurrency := flag.Int("concurrency", 16, "number of stores to check at the same time (0 for no limit)")
+	flag.Parse()
+
+	if *format != "table" && *format != "json" {
+		log.Fatalf("unknown format: %s", *format)
+	}
+
+	var required []string
+	for _, name := range strings.Split(*resources, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		resource, ok := shopify.LookupResource(name)
+		if !ok {
+			log.Fatalf("unknown resource: %s", name)
+		}
+
+		if !resource.AvailableIn(*apiVersion) {
+			log.Fatalf("resource %s is not available in API version %s", name, *apiVersion)
+		}
+
+		for _, scope := range resource.Scopes {
+			if !contains(required, scope) {
+				required = append(required, scope)
+			}
+		}
+	}
+
+	runtime := config.Runtime{StoresFile: *storesFile}
+	ch, err := runtime.LoadStores()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var results []*result
+	p := pool.New(context.Background(), pool.WithConcurrency(*concurrency))
+	for store := range ch {
+		store, r := store, &result{StoreID: store.StoreID}
+		results = append(results, r)
+		p.Go(store.StoreID, func(ctx context.Context) error {
+			check(ctx, r, store, *apiVersion, required)
+			return nil
+		})
+	}
+
+	// tasks only fail if check panicked; results are in submission order
+	done, _ := p.Wait()
+	for idx, d := range done {
+		if d.Err != nil {
+			results[idx].OK = false
+			results[idx].Error = d.Err.Error()
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].StoreID < results[j].StoreID })
+
+	if *format == "json" {
+		err = writeJSON(os.Stdout, results)
+	} else {
+		err = writeTable(os.Stdout, results)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, r := range results {
+		if !r.OK {
+			os.Exit(1)
+		}
+	}
+}
+
+// check fills in r for store.
+func check(ctx context.Context, r *result, store *config.Store, apiVersion string, required []string) {
+	fail := func(err error) {
+		r.Condition, _ = shopify.StoreConditionOf(err)
+		r.Error = err.Error()
+	}
+
+	client, err := store.NewClient(shopify.WithAPIVersion(apiVersion))
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if r.Shop, err = client.Shop(ctx); err != nil {
+		fail(err)
+		return
+	}
+
+	if r.Scopes, err = client.AccessScopes(ctx); err != nil {
+		fail(err)
+		return
+	}
+
+	for _, scope := range required {
+		if !shopify.HasScope(r.Scopes, scope) {
+			r.MissingScopes = append(r.MissingScopes, scope)
+		}
+	}
+
+	r.BucketSize = client.RateLimiter().Info().BucketSize
+	r.OK = len(r.MissingScopes) == 0
+}
+
+func writeJSON(w io.Writer, results []*result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+func writeTable(w io.Writer, results []*result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STORE\tSTATUS\tPLAN\tTIMEZONE\tCURRENCY\tBUCKET\tDETAILS")
+	for _, r := range results {
+		status, details := "ok", ""
+		switch {
+		case r.Error != "":
+			status, details = "error", r.Error
+			if r.Condition != "" {
+				status = string(r.Condition)
+			}
+		case len(r.MissingScopes) > 0:
+			status, details = "missing scopes", strings.Join(r.MissingScopes, ",")
+		}
+
+		var plan, timezone, currency, bucket string
+		if r.Shop != nil {
+			plan, timezone, currency = r.Shop.PlanName, r.Shop.IANATimezone, r.Shop.Currency
+		}
+		if r.BucketSize > 0 {
+			bucket = strconv.Itoa(r.BucketSize)
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.StoreID, status, plan, timezone, currency, bucket, details)
+	}
+	return tw.Flush()
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+func testResults() []*result {
+	return []*result{
+		{
+			StoreID:    "healthy",
+			OK:         true,
+			Shop:       &shopify.Shop{PlanName: "shopify_plus", IANATimezone: "America/New_York", Currency: "USD"},
+			Scopes:     []string{"read_orders"},
+			BucketSize: 80,
+		},
+		{
+			StoreID:       "missing",
+			Shop:          &shopify.Shop{PlanName: "basic"},
+			MissingScopes: []string{"read_customers", "read_products"},
+		},
+		{StoreID: "frozen", Condition: shopify.StoreFrozen, Error: "402: Payment Required"},
+		{StoreID: "broken", Error: "connection refused"},
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTable(&buf, testResults()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5:\n%s", len(lines), buf.String())
+	}
+
+	want := [][]string{
+		{"STORE", "STATUS", "PLAN", "TIMEZONE", "CURRENCY", "BUCKET", "DETAILS"},
+		{"healthy", "ok", "shopify_plus", "America/New_York", "USD", "80"},
+		{"missing", "missing scopes", "basic", "read_customers,read_products"},
+		// a store condition replaces the generic error status
+		{"frozen", "frozen", "402: Payment Required"},
+		{"broken", "error", "connection refused"},
+	}
+	for idx, fields := range want {
+		for _, field := range fields {
+			if !strings.Contains(lines[idx], field) {
+				t.Errorf("line %d = %q, want %q", idx, lines[idx], field)
+			}
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, testResults()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d results, want 4", len(got))
+	}
+	if got[0]["ok"] != true || got[0]["bucket_size"] != 80.0 {
+		t.Errorf("healthy = %v", got[0])
+	}
+	if got[2]["condition"] != "frozen" || got[2]["ok"] != false {
+		t.Errorf("frozen = %v", got[2])
+	}
+	// empty fields are left out
+	if _, ok := got[3]["shop"]; ok {
+		t.Errorf("broken = %v, want no shop", got[3])
+	}
+}
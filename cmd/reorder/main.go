@@ -1,23 +1,29 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/demosdemon/multierrgroup"
 	"github.com/hashicorp/errwrap"
 
 	"github.com/demosdemon/shop/pkg/data"
+	"github.com/demosdemon/shop/pkg/shopify"
 )
 
 func main() {
-	files := os.Args[1:]
+	latest := flag.Bool("latest", false, "keep only the latest version of each record id")
+	flag.Parse()
+	files := flag.Args()
 
 	var g multierrgroup.Group
 	for _, f := range files {
 		f := f
-		g.Go(reorder(f))
+		g.Go(reorder(f, *latest))
 	}
 
 	if err := g.Wait(); err != nil {
@@ -43,7 +49,7 @@ func (e reorderError) Error() string {
 	return fmt.Sprintf("error processing `%s`: %v", e.path, e.error)
 }
 
-func reorder(path string) func() error {
+func reorder(path string, latest bool) func() error {
 	return func() error {
 		log.Printf("opening %s", path)
 		fp, err := os.OpenFile(path, os.O_RDWR, 0666)
@@ -53,7 +59,7 @@ func reorder(path string) func() error {
 
 		defer func() { _ = fp.Close() }()
 
-		if err := data.Reorder(fp); err != nil {
+		if err := data.ReorderTimestamps(fp, timestamps(path), latest); err != nil {
 			return reorderError{path, err}
 		}
 
@@ -61,3 +67,12 @@ func reorder(path string) func() error {
 		return nil
 	}
 }
+
+// timestamps returns the timestamp fields of the resource a file was written
+// for, such as `orders.jsonl` or `orders.bulk.jsonl`. Unknown files are
+// assumed to have both created_at and updated_at.
+func timestamps(path string) data.Timestamps {
+	name := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	name = strings.TrimSuffix(name, ".bulk")
+	return shopify.ResourceFor(name).Timestamps
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/demosdemon/shop/internal/config"
+	"github.com/demosdemon/shop/internal/webhook"
+	"github.com/demosdemon/shop/pkg/pool"
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+func main() {
+	storesFile := flag.String("stores", "./stores.jsonl", "path to store configuration file")
+	address := flag.String("address", "", "URL the webhook receiver is reachable at")
+	topics := flag.String("topics", strings.Join(webhook.DefaultTopics, ","), "comma separated list of webhook topics to subscribe to")
+	apiVersion := flag.String("shopify-version", shopify.DefaultAPIVersion, "shopify API version")
+	dryRun := flag.Bool("dryrun", false, "print the plan without changing any subscriptions")
+	concurrency := flag.Int("concurrency", 16, "number of stores to subscribe at the same time (0 for no limit)")
+	flag.Parse()
+
+	if *address == "" {
+		log.Fatal("-address is required")
+	}
+
+	var desired []string
+	for _, topic := range strings.Split(*topics, ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			desired = append(desired, topic)
+		}
+	}
+
+	runtime := config.Runtime{StoresFile: *storesFile}
+	ch, err := runtime.LoadStores()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	p := pool.New(context.Background(), pool.WithConcurrency(*concurrency))
+	for store := range ch {
+		store := store
+		p.Go(store.StoreID, func(ctx context.Context) error {
+			var buf bytes.Buffer
+			err := subscribe(ctx, &buf, store, *apiVersion, desired, *address, *dryRun)
+
+			// print each store's plan in one piece
+			mu.Lock()
+			defer mu.Unlock()
+			_, _ = os.Stdout.Write(buf.Bytes())
+			return err
+		})
+	}
+
+	if _, err := p.Wait(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func subscribe(ctx context.Context, w *bytes.Buffer, store *config.Store, apiVersion string, topics []string, address string, dryRun bool) error {
+	// the receiver rejects the webhooks of such stores
+	if store.WebhookSecret == "" {
+		_, _ = fmt.Fprintf(w, "%s: skipped, no webhook secret\n", store.StoreID)
+		return nil
+	}
+
+	client, err := store.NewClient(shopify.WithAPIVersion(apiVersion))
+	if err != nil {
+		return err
+	}
+
+	existing, err := client.Webhooks.List(ctx, shopify.ListOptions{Limit: 250})
+	if err != nil {
+		return fmt.Errorf("error listing webhooks for %s: %v", store.StoreID, err)
+	}
+
+	changes := webhook.Plan(existing, topics, address)
+	if len(changes) == 0 {
+		_, _ = fmt.Fprintf(w, "%s: up to date\n", store.StoreID)
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(w, "%s: %d changes\n", store.StoreID, len(changes))
+	for _, c := range changes {
+		_, _ = fmt.Fprintf(w, "  %s\n", c)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if err := webhook.Apply(ctx, client, changes); err != nil {
+		return fmt.Errorf("error updating webhooks for %s: %v", store.StoreID, err)
+	}
+
+	_, _ = fmt.Fprintf(w, "%s: applied\n", store.StoreID)
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	_log "log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/demosdemon/shop/internal/config"
+	"github.com/demosdemon/shop/internal/webhook"
+	"github.com/demosdemon/shop/pkg/log"
+)
+
+func main() {
+	listen := flag.String("listen", ":8080", "address to listen on")
+	storesFile := flag.String("stores", "./stores.jsonl", "path to store configuration file")
+	output := flag.String("output", "./out", "output directory to append records to")
+	path := flag.String("path", "/webhooks", "path webhooks are posted to")
+	grace := flag.Duration("grace", 30*time.Second, "time to wait for in-flight webhooks when stopping")
+	flag.Parse()
+
+	logger := log.NewLogger(log.LevelInfo, os.Stderr, "[webhook] ")
+
+	runtime := config.Runtime{StoresFile: *storesFile}
+	ch, err := runtime.LoadStores()
+	if err != nil {
+		_log.Fatal(err)
+	}
+
+	var stores []*config.Store
+	for store := range ch {
+		stores = append(stores, store)
+	}
+
+	files := webhook.NewFiles(*output)
+	mux := http.NewServeMux()
+	mux.Handle(*path, webhook.NewHandler(logger, files, stores))
+	srv := &http.Server{Addr: *listen, Handler: mux}
+
+	// ListenAndServe returns as soon as Shutdown is called; the files are
+	// closed only after in-flight webhooks are written
+	stopped := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer close(stopped)
+		<-sig
+		logger.Infof("shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), *grace)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Errorf("error shutting down: %v", err)
+		}
+	}()
+
+	logger.Infof("listening on %s for webhooks from %d stores", *listen, len(stores))
+	err = srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		<-stopped
+		err = nil
+	}
+	if cErr := files.Close(); cErr != nil {
+		logger.Errorf("error closing files: %v", cErr)
+	}
+	if err != nil {
+		_log.Fatal(err)
+	}
+}
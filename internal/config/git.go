@@ -233,23 +233,33 @@ func ReadStores(path, hash string, r io.Reader, resolver secrets.Resolver) ([]*S
 				return nil, err
 			}
 
-			username, err := get(ctx, resolver, integration.Options.Get("key"))
-			if err != nil {
-				return nil, err
+			store := &Store{
+				File:    path,
+				ID:      id,
+				StoreID: storeId,
+				Commit:  hash,
 			}
 
-			password, err := get(ctx, resolver, integration.Options.Get("password"))
-			if err != nil {
-				return nil, err
+			// stores installed as custom apps only have an access token
+			if token := integration.Options.Get("access_token"); token != nil {
+				store.AuthType = AuthTypeAccessToken
+				if store.AccessToken, err = get(ctx, resolver, token); err != nil {
+					return nil, err
+				}
+			} else {
+				store.AuthType = AuthTypeBasic
+				if store.Username, err = get(ctx, resolver, integration.Options.Get("key")); err != nil {
+					return nil, err
+				}
+				if store.Password, err = get(ctx, resolver, integration.Options.Get("password")); err != nil {
+					return nil, err
+				}
 			}
 
-			store := &Store{
-				File:     path,
-				ID:       id,
-				StoreID:  storeId,
-				Username: username,
-				Password: password,
-				Commit:   hash,
+			if secret := integration.Options.Get("webhook_secret"); secret != nil {
+				if store.WebhookSecret, err = get(ctx, resolver, secret); err != nil {
+					return nil, err
+				}
 			}
 
 			stores = append(stores, store)
@@ -11,8 +11,10 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
+	"github.com/demosdemon/shop/pkg/retry"
 	"github.com/demosdemon/shop/pkg/shopify"
 )
 
@@ -22,13 +24,25 @@ type Runtime struct {
 	OutputDirectory    string
 	PeriodicStackDump  bool
 	StackDumpFrequency time.Duration
+	Concurrency        int
 	DryRun             bool
+	Resources          []string
+	Bulk               bool
+	BulkPollInterval   time.Duration
+	Shards             int
+	TrackDeletions     bool
+	ReconcileEvery     time.Duration
 	ShopifyAPIVersion  string
 	HTTPTimeout        time.Duration
 	HTTPRetryCount     int
-	HTTPRetryDelay     time.Duration
-	HTTPRetryJitter    time.Duration
+	HTTPRetryBackoff   retry.Backoff
+	HTTPRetryElapsed   time.Duration
+	HTTPAttemptTimeout time.Duration
+	BreakerThreshold   int
+	BreakerCoolDown    time.Duration
 	HTTPUserAgent      string
+	RecordDirectory    string
+	ReplayDirectory    string
 }
 
 func (r *Runtime) ParseArgs(args []string) error {
@@ -41,14 +55,86 @@ func (r *Runtime) ParseArgs(args []string) error {
 	f.StringVar(&r.OutputDirectory, "output", "./out", "output directory to store results")
 	f.BoolVar(&r.PeriodicStackDump, "stack", false, "periodically dump stack traces to `.trace` files in the output directory")
 	f.DurationVar(&r.StackDumpFrequency, "period", time.Minute, "duration between stack dumps")
+	f.IntVar(&r.Concurrency, "concurrency", 16, "number of stores to sync at the same time (0 for no limit)")
 	f.BoolVar(&r.DryRun, "dryrun", false, "do not actually call shopify apis")
+	f.BoolVar(&r.Bulk, "bulk", false, "also export each element with a GraphQL bulk operation on its initial fetch, written to <element>.bulk.jsonl since its records have fewer fields in a different shape")
+	f.DurationVar(&r.BulkPollInterval, "bulk-poll", shopify.DefaultBulkPollInterval, "duration between bulk operation status checks")
+	f.IntVar(&r.Shards, "shards", 1, "split the initial fetch of each element into this many created_at windows fetched concurrently")
+	f.BoolVar(&r.TrackDeletions, "deletions", true, "append tombstones for records deleted since the last run")
+	f.DurationVar(&r.ReconcileEvery, "reconcile-every", 24*time.Hour, "duration between passes that compare every id with shopify to find deleted records of resources the events resource does not report, such as customers and collections")
 	f.StringVar(&r.ShopifyAPIVersion, "shopify-version", shopify.DefaultAPIVersion, "shopify API version")
 	f.DurationVar(&r.HTTPTimeout, "timeout", shopify.DefaultHTTPTimeout, "http timeout per request (some requests may take a long time)")
 	f.IntVar(&r.HTTPRetryCount, "retries", shopify.DefaultRetryCount, "number of attempts to retry each HTTP request before failing")
-	f.DurationVar(&r.HTTPRetryDelay, "delay", shopify.DefaultRetryDelay, "minimum delay to wait before retrying after failures (rate limited errors are handled separately)")
-	f.DurationVar(&r.HTTPRetryJitter, "jitter", shopify.DefaultRetryJitter, "random jitter amount to add in to each wait period")
+	backoff := f.String("backoff", shopify.DefaultRetryBackoff, "backoff between retries as `name:base[:max]`, where name is constant, exponential, full-jitter, decorrelated-jitter or fibonacci (rate limited errors wait at least as long as shopify asks)")
+	f.DurationVar(&r.HTTPRetryElapsed, "retry-elapsed", 0, "maximum total time to spend retrying each HTTP request (0 for no limit)")
+	f.DurationVar(&r.HTTPAttemptTimeout, "attempt-timeout", 0, "deadline for each attempt of an HTTP request, including waiting for the rate limiter (0 for none)")
+	f.IntVar(&r.BreakerThreshold, "breaker", 5, "consecutive server errors or timeouts after which a store's requests fail fast (0 to disable)")
+	f.DurationVar(&r.BreakerCoolDown, "breaker-cooldown", time.Minute, "duration to fail fast before probing a store again")
 	f.StringVar(&r.HTTPUserAgent, "user-agent", shopify.DefaultUserAgent, "user-agent to use in HTTP requests")
-	return f.Parse(args)
+	f.StringVar(&r.RecordDirectory, "record", "", "record each store's HTTP interactions to a cassette in this directory")
+	f.StringVar(&r.ReplayDirectory, "replay", "", "replay each store's HTTP interactions from a cassette in this directory instead of calling shopify")
+	resources := f.String("resources", strings.Join(shopify.DefaultResources, ","), "comma separated list of resources to sync")
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	if r.Shards < 1 {
+		return fmt.Errorf("-shards must be at least 1")
+	}
+
+	var err error
+	if r.HTTPRetryBackoff, err = retry.ParseBackoff(*backoff, nil); err != nil {
+		return err
+	}
+
+	if r.BreakerThreshold < 0 {
+		return fmt.Errorf("-breaker must not be negative")
+	}
+
+	if r.Bulk && r.ShopifyAPIVersion < shopify.BulkOperationsVersion {
+		return fmt.Errorf("-bulk requires API version %s or later", shopify.BulkOperationsVersion)
+	}
+
+	if r.RecordDirectory != "" && r.ReplayDirectory != "" {
+		return fmt.Errorf("-record and -replay cannot be used together")
+	}
+
+	r.Resources = nil
+	for _, name := range strings.Split(*resources, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		resource, ok := shopify.LookupResource(name)
+		if !ok {
+			return fmt.Errorf("unknown resource: %s", name)
+		}
+
+		if !resource.AvailableIn(r.ShopifyAPIVersion) {
+			return fmt.Errorf("resource %s is not available in API version %s", name, r.ShopifyAPIVersion)
+		}
+
+		r.Resources = append(r.Resources, name)
+	}
+
+	for _, name := range r.Resources {
+		resource, _ := shopify.LookupResource(name)
+		if resource.Parent != "" && !r.HasResource(resource.Parent) {
+			return fmt.Errorf("resource %s requires resource %s", name, resource.Parent)
+		}
+	}
+
+	return nil
+}
+
+func (r *Runtime) HasResource(name string) bool {
+	for _, v := range r.Resources {
+		if v == name {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *Runtime) LoadStores() (<-chan *Store, error) {
@@ -1,10 +1,49 @@
 package config
 
+import (
+	"fmt"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+const (
+	// AuthTypeBasic authenticates legacy private apps with their API key and
+	// password.
+	AuthTypeBasic = "basic"
+
+	// AuthTypeAccessToken authenticates custom and public apps with an Admin
+	// API access token.
+	AuthTypeAccessToken = "access_token"
+)
+
 type Store struct {
-	File     string `json:"file"`
-	ID       string `json:"id"`
-	StoreID  string `json:"store_id"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Commit   string `json:"commit,omitempty"`
+	File        string `json:"file"`
+	ID          string `json:"id"`
+	StoreID     string `json:"store_id"`
+	AuthType    string `json:"auth_type,omitempty"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	AccessToken string `json:"access_token,omitempty"`
+
+	// WebhookSecret verifies the signatures of the store's webhooks.
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+
+	Commit string `json:"commit,omitempty"`
+}
+
+// NewClient returns a client for the store authenticated according to its
+// auth type. An empty auth type is basic auth.
+func (s *Store) NewClient(options ...shopify.Option) (*shopify.Client, error) {
+	switch s.AuthType {
+	case "", AuthTypeBasic:
+		return shopify.New(s.StoreID, s.Username, s.Password, options...), nil
+	case AuthTypeAccessToken:
+		if s.AccessToken == "" {
+			return nil, fmt.Errorf("store %s has no access token", s.StoreID)
+		}
+		options = append([]shopify.Option{shopify.WithAccessToken(s.AccessToken)}, options...)
+		return shopify.New(s.StoreID, "", "", options...), nil
+	default:
+		return nil, fmt.Errorf("store %s has unknown auth type: %s", s.StoreID, s.AuthType)
+	}
 }
@@ -0,0 +1,96 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/demosdemon/shop/pkg/data"
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+// BulkSuffix names the file bulk operation results are written to, next to
+// the element's output file. Bulk records have a subset of the REST fields in
+// a different shape, so the two are kept apart.
+const BulkSuffix = ".bulk.jsonl"
+
+// bulk exports the resource with a bulk operation into its own file,
+// replacing any earlier export.
+func (j *Job) bulk(ctx context.Context, query string) (err error) {
+	if j.DryRun {
+		j.Warnf("dry run enabled; would have run a bulk operation for %s", j.Element)
+		return nil
+	}
+
+	op, err := j.Client.RunBulkQuery(ctx, query)
+	if err != nil {
+		j.Errorf("error starting bulk operation: %v", err)
+		return err
+	}
+	id := op.ID
+	j.Infof("started bulk operation %s", id)
+
+	op, err = j.Client.WaitBulkOperation(ctx, id, j.BulkPollInterval)
+	if err != nil {
+		j.Errorf("error waiting for bulk operation: %v", err)
+		if ctx.Err() != nil {
+			// the run is shutting down, don't leave the operation running
+			if _, cErr := j.Client.CancelBulkOperation(context.Background(), id); cErr != nil {
+				j.Warnf("error canceling bulk operation %s: %v", id, cErr)
+			}
+		}
+		return err
+	}
+
+	if op.Status != shopify.BulkOperationCompleted {
+		code := "(null)"
+		if op.ErrorCode != nil {
+			code = *op.ErrorCode
+		}
+		return fmt.Errorf("bulk operation %s is %s with error code %s", op.ID, op.Status, code)
+	}
+	j.Infof("bulk operation %s completed with %s objects", op.ID, op.ObjectCount)
+
+	body, err := j.Client.DownloadBulkOperation(ctx, op)
+	if err != nil {
+		j.Errorf("error downloading bulk operation: %v", err)
+		return err
+	}
+	defer func() {
+		if cErr := body.Close(); err == nil {
+			err = cErr
+		}
+	}()
+
+	output := filepath.Join(j.OutputDirectory, j.StoreID, j.Element+BulkSuffix)
+	if err := j.download(body, output); err != nil {
+		j.Errorf("error writing bulk operation results: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (j *Job) download(body io.Reader, output string) (err error) {
+	fp, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cErr := fp.Close(); err == nil {
+			err = cErr
+		}
+	}()
+
+	w := data.NewWriter(fp)
+	r := data.NewTimestampReader(body, j.Resource.Timestamps)
+	for r.Scan() {
+		if err := w.Write(r.Item()); err != nil {
+			return err
+		}
+	}
+
+	return r.Err()
+}
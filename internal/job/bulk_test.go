@@ -0,0 +1,46 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+func TestBulkExportDoesNotMoveWatermark(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	dir := tempDir(t)
+	seed(t, s, "orders", 1, 4)
+
+	j := newJob(t, s, dir, "orders")
+	j.Bulk = true
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the export is kept on the side, and the REST fetch still runs
+	checkAllIDs(t, readItems(t, dir, "orders"+BulkSuffix), 1, 4)
+	checkAllIDs(t, readItems(t, dir, "orders.jsonl"), 1, 4)
+
+	// the window is that of the REST records, so the next run is
+	// incremental and does not export again
+	seed(t, s, "orders", 5, 5)
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if items := readItems(t, dir, "orders"+BulkSuffix); len(items) != 4 {
+		t.Errorf("bulk export has %d records after an incremental run, want 4", len(items))
+	}
+	checkAllIDs(t, readItems(t, dir, "orders.jsonl"), 1, 5)
+
+	state, err := j.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.First.Equal(epoch.Add(time.Minute)) || !state.Last.Equal(epoch.Add(5*time.Minute)) {
+		t.Errorf("window = %s - %s", state.First, state.Last)
+	}
+}
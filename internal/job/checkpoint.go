@@ -0,0 +1,140 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+const pageLimit = 250
+
+// Window is the updated_at or created_at range of a single pagination run. A
+// zero bound is unbounded.
+type Window struct {
+	UpdatedAtMin time.Time `json:"updated_at_min"`
+	UpdatedAtMax time.Time `json:"updated_at_max"`
+	CreatedAtMin time.Time `json:"created_at_min"`
+	CreatedAtMax time.Time `json:"created_at_max"`
+}
+
+func (w Window) options() shopify.ListOptions {
+	return shopify.ListOptions{
+		UpdatedAtMin: w.UpdatedAtMin,
+		UpdatedAtMax: w.UpdatedAtMax,
+		CreatedAtMin: w.CreatedAtMin,
+		CreatedAtMax: w.CreatedAtMax,
+		Limit:        pageLimit,
+	}
+}
+
+func (w Window) String() string {
+	if !w.CreatedAtMin.IsZero() || !w.CreatedAtMax.IsZero() {
+		return "created " + fmtTime(w.CreatedAtMin) + " - " + fmtTime(w.CreatedAtMax)
+	}
+	return fmtTime(w.UpdatedAtMin) + " - " + fmtTime(w.UpdatedAtMax)
+}
+
+// Checkpoint records how far pagination of a window got. It is saved after
+// every page that has been written to the output file so an interrupted run
+// can continue from the next page.
+type Checkpoint struct {
+	Element  string    `json:"element"`
+	Window   Window    `json:"window"`
+	PageInfo string    `json:"page_info"`
+	Page     int       `json:"page"`
+	Records  int       `json:"records"`
+	SavedAt  time.Time `json:"saved_at"`
+
+	// Shard is the backfill shard the checkpoint belongs to, counting from
+	// one, or zero outside of a sharded backfill.
+	Shard int `json:"shard,omitempty"`
+}
+
+func (j *Job) newCheckpoint(window Window) *Checkpoint {
+	return &Checkpoint{Element: j.Element, Window: window}
+}
+
+// restart returns a checkpoint for the start of the same window.
+func (cp *Checkpoint) restart() *Checkpoint {
+	return &Checkpoint{Element: cp.Element, Window: cp.Window, Shard: cp.Shard}
+}
+
+func (j *Job) checkpointPath(shard int) string {
+	name := j.Element + ".checkpoint.json"
+	if shard > 0 {
+		name = fmt.Sprintf("%s.shard-%d.checkpoint.json", j.Element, shard)
+	}
+	return filepath.Join(j.OutputDirectory, j.StoreID, name)
+}
+
+// loadCheckpoint returns the saved checkpoint of the shard, or nil if there
+// is none.
+func (j *Job) loadCheckpoint(shard int) (*Checkpoint, error) {
+	buf, err := ioutil.ReadFile(j.checkpointPath(shard))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cp := new(Checkpoint)
+	if err := json.Unmarshal(buf, cp); err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+func (j *Job) saveCheckpoint(cp *Checkpoint) error {
+	cp.SavedAt = time.Now().UTC()
+	return writeFileAtomic(j.checkpointPath(cp.Shard), cp)
+}
+
+func (j *Job) clearCheckpoint(shard int) error {
+	err := os.Remove(j.checkpointPath(shard))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// writeFileAtomic encodes v as JSON to a temporary file next to path and
+// renames it into place, so readers never see a partially written file.
+func writeFileAtomic(path string, v interface{}) (err error) {
+	buf, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(fp.Name())
+		}
+	}()
+
+	if _, err := fp.Write(append(buf, '\n')); err != nil {
+		_ = fp.Close()
+		return err
+	}
+
+	if err := fp.Sync(); err != nil {
+		_ = fp.Close()
+		return err
+	}
+
+	if err := fp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(fp.Name(), path)
+}
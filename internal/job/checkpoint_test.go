@@ -0,0 +1,102 @@
+package job
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+// failAfterPages injects a persistent fault into the server once the given
+// number of pages of the element have been listed.
+type failAfterPages struct {
+	http.RoundTripper
+	server  *shopifytest.Server
+	element string
+	pages   int
+}
+
+func (f *failAfterPages) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := f.RoundTripper.RoundTrip(req)
+	if err == nil && strings.HasSuffix(req.URL.Path, "/"+f.element+".json") {
+		if f.pages--; f.pages == 0 {
+			f.server.Inject(shopifytest.Fault{Path: f.element, Status: http.StatusServiceUnavailable})
+		}
+	}
+	return res, err
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	dir := tempDir(t)
+
+	const total = 2*pageLimit + 100
+	seed(t, s, "orders", 1, total)
+
+	j := newJob(t, s, dir, "orders")
+	j.Client.Transport = &failAfterPages{RoundTripper: http.DefaultTransport, server: s, element: "orders", pages: 1}
+	if err := j.Do(context.Background()); err == nil {
+		t.Fatal("Do() succeeded with a failing second page")
+	}
+
+	cp, err := j.loadCheckpoint(0)
+	if err != nil || cp == nil {
+		t.Fatalf("loadCheckpoint() = %v, %v", cp, err)
+	}
+	if cp.Page != 1 || cp.Records != pageLimit || cp.PageInfo == "" {
+		t.Errorf("checkpoint = page %d, %d records, page_info %q", cp.Page, cp.Records, cp.PageInfo)
+	}
+	if items := readItems(t, dir, "orders.jsonl"); len(items) != pageLimit {
+		t.Fatalf("failed run wrote %d records, want %d", len(items), pageLimit)
+	}
+
+	s.ClearFaults()
+	j = newJob(t, s, dir, "orders")
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the resumed pages come first and continue where the first run stopped
+	items := readItems(t, dir, "orders.jsonl")
+	if len(items) < total {
+		t.Fatalf("wrote %d records, want at least %d", len(items), total)
+	}
+	for idx := 0; idx < total; idx++ {
+		if items[idx].ID != int64(idx+1) {
+			t.Fatalf("record %d has id %d, want %d", idx, items[idx].ID, idx+1)
+		}
+	}
+
+	if exists(t, j.checkpointPath(0)) {
+		t.Error("checkpoint was not removed")
+	}
+}
+
+func TestResumeFromRejectedCheckpoint(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	dir := tempDir(t)
+	seed(t, s, "orders", 1, 5)
+
+	j := newJob(t, s, dir, "orders")
+	if err := os.MkdirAll(filepath.Join(dir, storeID), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.saveCheckpoint(&Checkpoint{Element: "orders", PageInfo: "expired", Page: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	checkAllIDs(t, readItems(t, dir, "orders.jsonl"), 1, 5)
+	if exists(t, j.checkpointPath(0)) {
+		t.Error("checkpoint was not removed")
+	}
+}
@@ -0,0 +1,193 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/demosdemon/shop/pkg/data"
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+// ParentIDField is added to every child record with the id of its parent.
+const ParentIDField = "_parent_id"
+
+// Pending lists, per child resource, the parents whose children have not been
+// synced yet. It is saved before children are fetched, so parents fetched by a
+// run that fails or is cancelled have their children synced by the next run,
+// even though the parents themselves will not be fetched again.
+type Pending struct {
+	Element string             `json:"element"`
+	Parents map[string][]int64 `json:"parents"`
+	SavedAt time.Time          `json:"saved_at"`
+}
+
+func (j *Job) pendingPath() string {
+	return filepath.Join(j.OutputDirectory, j.StoreID, j.Element+".children.json")
+}
+
+// loadPending returns the saved pending children, or an empty list if there
+// is none.
+func (j *Job) loadPending() (*Pending, error) {
+	p := &Pending{Element: j.Element, Parents: make(map[string][]int64)}
+
+	buf, err := ioutil.ReadFile(j.pendingPath())
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(buf, p); err != nil {
+		return nil, err
+	}
+	if p.Parents == nil {
+		p.Parents = make(map[string][]int64)
+	}
+
+	return p, nil
+}
+
+// savePending saves p, or removes the file once nothing is pending.
+func (j *Job) savePending(p *Pending) error {
+	for name, ids := range p.Parents {
+		if len(ids) == 0 {
+			delete(p.Parents, name)
+		}
+	}
+
+	if len(p.Parents) == 0 {
+		err := os.Remove(j.pendingPath())
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	p.SavedAt = time.Now().UTC()
+	return writeFileAtomic(j.pendingPath(), p)
+}
+
+// queueChildren adds the parents fetched by this run to the pending list of
+// every child resource.
+func (j *Job) queueChildren(parentIDs []int64) error {
+	if len(j.Children) == 0 || len(parentIDs) == 0 || j.DryRun {
+		return nil
+	}
+
+	p, err := j.loadPending()
+	if err != nil {
+		return err
+	}
+
+	for _, child := range j.Children {
+		p.Parents[child.Name] = append(p.Parents[child.Name], parentIDs...)
+	}
+
+	return j.savePending(p)
+}
+
+// syncChildren pages through the child resources of every pending parent and
+// appends them to their own output files. The requests go through the same
+// client as the parent, and so share the store's rate limiter. A child that
+// fails does not stop the others; whatever was not synced stays pending.
+func (j *Job) syncChildren(ctx context.Context) error {
+	if len(j.Children) == 0 {
+		return nil
+	}
+
+	p, err := j.loadPending()
+	if err != nil {
+		j.Errorf("error loading pending children: %v", err)
+		return err
+	}
+
+	var errs error
+	for _, child := range j.Children {
+		if err := ctx.Err(); err != nil {
+			return multierror.Append(errs, err).ErrorOrNil()
+		}
+
+		parentIDs := p.Parents[child.Name]
+		if len(parentIDs) == 0 {
+			continue
+		}
+
+		if j.DryRun {
+			j.Warnf("dry run enabled; would have fetched %s for %d %s", child.Name, len(parentIDs), j.Element)
+			continue
+		}
+
+		j.Infof("fetching %s for %d %s", child.Name, len(parentIDs), j.Element)
+		done, err := j.syncChild(ctx, child, parentIDs)
+		p.Parents[child.Name] = parentIDs[done:]
+		if err != nil {
+			errs = multierror.Append(errs, errors.Wrap(err, child.Name))
+		}
+
+		if sErr := j.savePending(p); sErr != nil {
+			j.Errorf("error saving pending children: %v", sErr)
+			return multierror.Append(errs, sErr)
+		}
+	}
+
+	return errs
+}
+
+// syncChild fetches the children of each parent in turn and returns how many
+// parents were synced completely.
+func (j *Job) syncChild(ctx context.Context, child *shopify.Resource, parentIDs []int64) (done int, err error) {
+	output := filepath.Join(
+		j.OutputDirectory,
+		j.StoreID,
+		child.Name+".jsonl",
+	)
+
+	_ = os.MkdirAll(path.Dir(output), 0777)
+	fp, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	defer func() {
+		j.Debugf("collected %d %s", count, child.Name)
+		if cErr := fp.Close(); err == nil {
+			err = cErr
+		}
+	}()
+
+	w := data.NewWriter(fp)
+	for _, id := range parentIDs {
+		it := j.Client.IterateResource(child.ForParent(id), shopify.ListOptions{Limit: pageLimit})
+		for it.Next(ctx) {
+			item := it.Item()
+			if err := item.Annotate(ParentIDField, id); err != nil {
+				j.Errorf("error annotating %s: %v", child.Name, err)
+				return done, err
+			}
+
+			if err := w.Write(item); err != nil {
+				j.Errorf("error writing record to file: %v", err)
+				return done, err
+			}
+			count++
+		}
+
+		if err := it.Err(); err != nil {
+			j.Errorf("error fetching %s for %s %d: %v", child.Name, j.Element, id, err)
+			return done, err
+		}
+		done++
+	}
+
+	return done, nil
+}
@@ -0,0 +1,67 @@
+package job
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+func TestPendingChildrenSurviveFailure(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	dir := tempDir(t)
+	seed(t, s, "orders", 1, 3)
+	seed(t, s, "orders/1/transactions", 11, 12)
+	seed(t, s, "orders/2/transactions", 21, 21)
+	seed(t, s, "orders/3/transactions", 31, 32)
+
+	s.Inject(shopifytest.Fault{Path: "orders/2/transactions", Status: http.StatusInternalServerError})
+
+	transactions := shopify.ResourceFor("order_transactions")
+	j := newJob(t, s, dir, "orders")
+	j.Children = []*shopify.Resource{transactions}
+	err := j.Do(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "order_transactions") {
+		t.Fatalf("Do() = %v, want an order_transactions error", err)
+	}
+
+	// the parents were fetched and are not fetched again
+	checkAllIDs(t, readItems(t, dir, "orders.jsonl"), 1, 3)
+
+	children := readItems(t, dir, "order_transactions.jsonl")
+	if ids := idSet(children); len(ids) != 2 || ids[11] == 0 || ids[12] == 0 {
+		t.Errorf("synced transactions %v, want those of order 1", ids)
+	}
+	for _, child := range children {
+		if parent := gjson.GetBytes(child.Raw, ParentIDField).Int(); parent != child.ID/10 {
+			t.Errorf("transaction %d has parent %d", child.ID, parent)
+		}
+	}
+
+	p, err := j.loadPending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Parents[transactions.Name]; len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Fatalf("pending parents = %v, want [2 3]", got)
+	}
+
+	s.ClearFaults()
+	if err := j.syncChildren(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	checkAllIDs(t, readItems(t, dir, "order_transactions.jsonl"), 31, 32)
+	if ids := idSet(readItems(t, dir, "order_transactions.jsonl")); ids[21] != 1 {
+		t.Errorf("transaction 21 was written %d times, want once", ids[21])
+	}
+	if exists(t, j.pendingPath()) {
+		t.Error("pending children were not removed")
+	}
+}
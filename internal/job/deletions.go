@@ -0,0 +1,207 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/demosdemon/shop/pkg/data"
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+const (
+	// EventIDField is added to every tombstone with the id of the event
+	// that reported the deletion.
+	EventIDField = "_event_id"
+
+	// ReconciledField is added to every tombstone written by a
+	// reconciliation pass.
+	ReconciledField = "_reconciled"
+)
+
+// trackDeletions appends a tombstone for every record of the resource that
+// was deleted, either from the events resource or by reconciling the ids in
+// the output file with Shopify.
+func (j *Job) trackDeletions(ctx context.Context, state *State, write func(*data.Item) error) error {
+	if !j.TrackDeletions || ctx.Err() != nil {
+		return nil
+	}
+
+	switch {
+	case j.Resource.EventSubject != "":
+		return j.deletedEvents(ctx, state, write)
+	case j.Resource.Reconcile:
+		return j.reconcile(ctx, state, write)
+	default:
+		return nil
+	}
+}
+
+// deletedEvents appends a tombstone for every record that was deleted since
+// the events were last checked. The first run only records when checking
+// started; anything deleted before then was never fetched.
+func (j *Job) deletedEvents(ctx context.Context, state *State, write func(*data.Item) error) error {
+	subject := j.Resource.EventSubject
+
+	// events created while paginating are picked up by the next run; a
+	// tombstone written twice is harmless
+	start := time.Now().UTC()
+	since := state.DeletionsCheckedAt
+	if since.IsZero() {
+		j.Infof("tracking deleted %s from now on", j.Element)
+		state.DeletionsCheckedAt = start
+		return nil
+	}
+
+	options := shopify.EventListOptions{
+		Limit:        pageLimit,
+		CreatedAtMin: since,
+		Filter:       subject,
+		Verb:         shopify.EventVerbDestroy,
+	}
+
+	if j.DryRun {
+		j.Warnf("dry run enabled; would have fetched %s deleted since %s", j.Element, fmtTime(since))
+		return nil
+	}
+
+	j.Infof("fetching %s deleted since %s", j.Element, fmtTime(since))
+	it := j.Client.IterateResource(shopify.ResourceFor("events"), options)
+	defer func() { _ = it.Close() }()
+
+	count := 0
+	for it.Next(ctx) {
+		var event shopify.Event
+		if err := json.Unmarshal(it.Item().Raw, &event); err != nil {
+			j.Errorf("error decoding event: %v", err)
+			return err
+		}
+
+		// the filters are advisory; older API versions ignore verb
+		if event.SubjectType != subject || event.Verb != shopify.EventVerbDestroy {
+			continue
+		}
+
+		tombstone := data.NewTombstone(event.SubjectID, event.CreatedAt)
+		if err := tombstone.Annotate(EventIDField, event.ID); err != nil {
+			j.Errorf("error annotating tombstone: %v", err)
+			return err
+		}
+
+		if err := write(tombstone); err != nil {
+			return err
+		}
+		count++
+	}
+
+	if err := it.Err(); err != nil {
+		j.Errorf("error fetching events: %v", err)
+		return err
+	}
+
+	j.Infof("found %d deleted %s", count, j.Element)
+	state.DeletionsCheckedAt = start
+	return nil
+}
+
+// reconcile appends a tombstone, timestamped now, for every record in the
+// output file whose id Shopify no longer lists. Listing every id is expensive,
+// so it runs at most once every ReconcileEvery.
+func (j *Job) reconcile(ctx context.Context, state *State, write func(*data.Item) error) error {
+	start := time.Now().UTC()
+	if checked := state.DeletionsCheckedAt; !checked.IsZero() && start.Sub(checked) < j.ReconcileEvery {
+		return nil
+	}
+
+	if j.DryRun {
+		j.Warnf("dry run enabled; would have reconciled %s ids", j.Element)
+		return nil
+	}
+
+	ids, err := j.liveIDs(ctx)
+	if err != nil {
+		j.Errorf("error scanning ids: %v", err)
+		return err
+	}
+
+	fields := "id," + j.Resource.Timestamps.CreatedAt
+	if j.Resource.Timestamps.UpdatedAt != "" {
+		fields += "," + j.Resource.Timestamps.UpdatedAt
+	}
+
+	j.Infof("reconciling %d %s ids", len(ids), j.Element)
+	it := j.Client.IterateResource(j.Resource, shopify.ListOptions{Limit: pageLimit, Fields: fields})
+	defer func() { _ = it.Close() }()
+
+	for it.Next(ctx) {
+		delete(ids, it.Item().ID)
+	}
+
+	if err := it.Err(); err != nil {
+		j.Errorf("error listing %s ids: %v", j.Element, err)
+		return err
+	}
+
+	deleted := make([]int64, 0, len(ids))
+	for id := range ids {
+		deleted = append(deleted, id)
+	}
+	sort.Slice(deleted, func(a, b int) bool { return deleted[a] < deleted[b] })
+
+	for _, id := range deleted {
+		tombstone := data.NewTombstone(id, start)
+		if err := tombstone.Annotate(ReconciledField, true); err != nil {
+			j.Errorf("error annotating tombstone: %v", err)
+			return err
+		}
+
+		if err := write(tombstone); err != nil {
+			return err
+		}
+	}
+
+	j.Infof("found %d deleted %s", len(deleted), j.Element)
+	state.DeletionsCheckedAt = start
+	return nil
+}
+
+// liveIDs returns the ids of the records in the output file that have no
+// tombstone.
+func (j *Job) liveIDs(ctx context.Context) (map[int64]bool, error) {
+	ids := make(map[int64]bool)
+	err := j.scanIDs(ctx, filepath.Join(j.OutputDirectory, j.StoreID, j.Element+".jsonl"), ids)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (j *Job) scanIDs(ctx context.Context, path string, ids map[int64]bool) error {
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fp.Close() }()
+
+	dr := data.NewTimestampReader(fp, j.Resource.Timestamps)
+	for dr.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		item := dr.Item()
+		switch {
+		case item.ID == 0:
+		case item.Deleted:
+			delete(ids, item.ID)
+		default:
+			ids[item.ID] = true
+		}
+	}
+
+	return dr.Err()
+}
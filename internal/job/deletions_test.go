@@ -0,0 +1,139 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/demosdemon/shop/pkg/data"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+func TestDeletedEvents(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	dir := tempDir(t)
+	seed(t, s, "orders", 1, 3)
+
+	j := newJob(t, s, dir, "orders")
+	j.TrackDeletions = true
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first run only starts tracking
+	state, err := j.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.DeletionsCheckedAt.IsZero() {
+		t.Fatal("DeletionsCheckedAt was not set")
+	}
+
+	now := time.Now().UTC().Add(time.Minute).Format(time.RFC3339)
+	events := []map[string]interface{}{
+		{"id": 901, "subject_id": 2, "subject_type": "Order", "verb": "destroy", "created_at": now},
+		{"id": 902, "subject_id": 3, "subject_type": "Order", "verb": "confirmed", "created_at": now},
+		{"id": 903, "subject_id": 7, "subject_type": "Product", "verb": "destroy", "created_at": now},
+	}
+	for _, event := range events {
+		if err := s.Seed("events", event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var tombstones []*data.Item
+	for _, item := range readItems(t, dir, "orders.jsonl") {
+		if item.Deleted {
+			tombstones = append(tombstones, item)
+		}
+	}
+	if len(tombstones) != 1 || tombstones[0].ID != 2 {
+		t.Fatalf("tombstones = %v, want one for order 2", tombstones)
+	}
+	if id := gjson.GetBytes(tombstones[0].Raw, EventIDField).Int(); id != 901 {
+		t.Errorf("%s = %d, want 901", EventIDField, id)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	dir := tempDir(t)
+
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seed(t, s, "customers", 1, 3)
+
+	j := newJob(t, s, dir, "customers")
+	j.TrackDeletions = true
+	j.ReconcileEvery = 0
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// customer 2 is deleted
+	s2 := shopifytest.NewServer()
+	defer s2.Close()
+	if err := s2.Seed("customers", newRecord(1, 1), newRecord(3, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 0; run < 2; run++ {
+		j := newJob(t, s2, dir, "customers")
+		j.TrackDeletions = true
+		j.ReconcileEvery = 0
+		if err := j.Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		var tombstones []*data.Item
+		for _, item := range readItems(t, dir, "customers.jsonl") {
+			if item.Deleted {
+				tombstones = append(tombstones, item)
+			}
+		}
+		if len(tombstones) != 1 || tombstones[0].ID != 2 {
+			t.Fatalf("run %d: tombstones = %v, want one for customer 2", run, tombstones)
+		}
+		if !gjson.GetBytes(tombstones[0].Raw, ReconciledField).Bool() {
+			t.Errorf("tombstone is missing %s", ReconciledField)
+		}
+	}
+}
+
+func TestReconcileIsThrottled(t *testing.T) {
+	dir := tempDir(t)
+
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seed(t, s, "customers", 1, 3)
+
+	j := newJob(t, s, dir, "customers")
+	j.TrackDeletions = true
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s2 := shopifytest.NewServer()
+	defer s2.Close()
+	if err := s2.Seed("customers", newRecord(1, 1), newRecord(3, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	j = newJob(t, s2, dir, "customers")
+	j.TrackDeletions = true
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range readItems(t, dir, "customers.jsonl") {
+		if item.Deleted {
+			t.Fatalf("reconciled again within ReconcileEvery: %s", item.Raw)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package job
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demosdemon/shop/internal/config"
+	"github.com/demosdemon/shop/pkg/data"
+	"github.com/demosdemon/shop/pkg/log"
+	"github.com/demosdemon/shop/pkg/retry"
+	"github.com/demosdemon/shop/pkg/shopify"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+const storeID = "test-store"
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type record struct {
+	ID        int64  `json:"id"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// newRecord returns a record created and last updated the given number of
+// minutes after epoch.
+func newRecord(id int64, minutes int) record {
+	ts := epoch.Add(time.Duration(minutes) * time.Minute).Format(time.RFC3339)
+	return record{ID: id, CreatedAt: ts, UpdatedAt: ts}
+}
+
+// seed adds records with ids from..to, each updated a minute after the last.
+func seed(t *testing.T, s *shopifytest.Server, element string, from, to int64) {
+	t.Helper()
+	for id := from; id <= to; id++ {
+		if err := s.Seed(element, newRecord(id, int(id))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+type testWriter struct {
+	t *testing.T
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+func newClient(s *shopifytest.Server) *shopify.Client {
+	return s.Client(storeID,
+		shopify.WithRateLimiter(shopify.NewRateLimiter()),
+		shopify.WithRetryBackoff(retry.Constant(time.Millisecond)),
+		shopify.WithRetryCount(2),
+	)
+}
+
+func newJob(t *testing.T, s *shopifytest.Server, dir, element string) *Job {
+	logger := log.NewLogger(log.LevelDebug, testWriter{t}, "")
+	client := newClient(s)
+	shopify.WithLogger(logger)(client)
+
+	return &Job{
+		Logger: logger,
+		Store:  &config.Store{StoreID: storeID},
+		Runtime: &config.Runtime{
+			OutputDirectory:  dir,
+			BulkPollInterval: time.Millisecond,
+			ReconcileEvery:   24 * time.Hour,
+		},
+		Client:   client,
+		Element:  element,
+		Resource: shopify.ResourceFor(element),
+	}
+}
+
+// readItems returns the records in one of the store's output files.
+func readItems(t *testing.T, dir, name string) []*data.Item {
+	t.Helper()
+	fp, err := os.Open(filepath.Join(dir, storeID, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = fp.Close() }()
+
+	var items []*data.Item
+	r := data.NewTimestampReader(fp, data.DefaultTimestamps)
+	for r.Scan() {
+		items = append(items, r.Item().Clone())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func idSet(items []*data.Item) map[int64]int {
+	ids := make(map[int64]int)
+	for _, item := range items {
+		if !item.Deleted {
+			ids[item.ID]++
+		}
+	}
+	return ids
+}
+
+func checkAllIDs(t *testing.T, items []*data.Item, from, to int64) {
+	t.Helper()
+	ids := idSet(items)
+	for id := from; id <= to; id++ {
+		if ids[id] == 0 {
+			t.Fatalf("id %d is missing", id)
+		}
+	}
+}
+
+func exists(t *testing.T, path string) bool {
+	t.Helper()
+	_, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
@@ -3,14 +3,18 @@ package job
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/google/go-querystring/query"
 	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
 
 	"github.com/demosdemon/shop/internal/config"
 	"github.com/demosdemon/shop/pkg/data"
@@ -22,8 +26,12 @@ type Job struct {
 	log.Logger
 	*config.Store
 	*config.Runtime
-	Client  *shopify.Client
-	Element string
+	Client   *shopify.Client
+	Element  string
+	Resource *shopify.Resource
+
+	// Children are synced for every record of the resource fetched in a run.
+	Children []*shopify.Resource
 }
 
 func (j *Job) Do(ctx context.Context) error {
@@ -33,152 +41,351 @@ func (j *Job) Do(ctx context.Context) error {
 	}
 
 	if j.Client == nil {
-		j.Client = shopify.New(j.StoreID, j.Username, j.Password, shopify.WithLogger(j))
+		client, err := j.Store.NewClient(shopify.WithLogger(j))
+		if err != nil {
+			return err
+		}
+		j.Client = client
+	}
+
+	if j.Resource == nil {
+		j.Resource = shopify.ResourceFor(j.Element)
+	}
+
+	err := j.do(ctx)
+	if condition, ok := shopify.StoreConditionOf(err); ok {
+		j.Warnf("skipping store, it is %s: %v", condition, err)
+		return StoreUnavailableError{StoreID: j.StoreID, Condition: condition, Err: err}
 	}
+	return err
+}
+
+// StoreUnavailableError is returned by a job whose store cannot be synced at
+// all, as opposed to a sync that failed.
+type StoreUnavailableError struct {
+	StoreID   string
+	Condition shopify.StoreCondition
+	Err       error
+}
+
+func (e StoreUnavailableError) Error() string {
+	return fmt.Sprintf("store %s is %s: %v", e.StoreID, e.Condition, e.Err)
+}
 
-	return j.do(ctx)
+func (e StoreUnavailableError) Unwrap() error {
+	return e.Err
 }
 
 func (j *Job) do(ctx context.Context) (err error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	fp, first, last, err := j.getMinMaxUpdatedAt(ctx)
+	start := time.Now().UTC()
+	fp, state, err := j.open(ctx)
 	if err != nil {
 		j.Errorf("error scanning existing file: %v", err)
 		return
 	}
+	first, last := state.First, state.Last
 
-	var wg sync.WaitGroup
-	defer wg.Wait()
-
-	results := make(chan *data.Item)
-	defer close(results)
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		w := data.NewWriter(fp)
-		count := 0
-		for v := range results {
-			if wErr := w.Write(v); wErr != nil {
-				j.Errorf("error writing record to file: %v", wErr)
-				err = multierror.Append(err, wErr)
-				cancel()
-			}
-			count++
+	w := data.NewLockedWriter(fp)
+	count := 0
+	var wErr error
+	var parentIDs []int64
+	collect := func(item *data.Item) {
+		count++
+		if len(j.Children) > 0 && item.ID != 0 && !item.Deleted {
+			parentIDs = append(parentIDs, item.ID)
 		}
+	}
+	write := func(item *data.Item) error {
+		if wErr = w.Write(item); wErr != nil {
+			j.Errorf("error writing record to file: %v", wErr)
+			return wErr
+		}
+		state.observe(item)
+		collect(item)
+		return nil
+	}
 
+	defer func() {
+		j.Debugf("collected %d records", count)
+
+		offset, pErr := fp.Seek(0, io.SeekCurrent)
 		if cErr := fp.Close(); cErr != nil {
 			j.Errorf("error closing file: %v", cErr)
 			err = multierror.Append(err, cErr)
+			return
 		}
 
-		j.Debugf("collected %d records", count)
-	}()
+		// a failed write may have left a partial record behind; leave the
+		// state stale so the next run scans the file
+		if pErr != nil || wErr != nil || j.DryRun {
+			return
+		}
 
-	forward := func(options interface{}) error {
-		if j.DryRun {
-			v, _ := query.Values(options)
-			s := v.Encode()
-			j.Warnf("dry run enabled; would have paginated %s with options: %s", j.Element, s)
-			return nil
+		state.Offset = offset
+		state.LastRun = start
+		if sErr := j.saveState(state); sErr != nil {
+			j.Errorf("error saving state: %v", sErr)
+			err = multierror.Append(err, sErr)
 		}
+	}()
 
-		ch, err := j.Client.Paginate(ctx, j.Element, options)
-		if err != nil {
-			j.Errorf("error starting pagination thread: %v", err)
+	// the records that were fetched will not be fetched again, even if the
+	// run failed partway, so their ids are queued first and their children
+	// are synced now or, if that fails too, by a later run
+	err = j.fetch(ctx, first, last, write)
+	cErr := j.queueChildren(parentIDs)
+	if cErr != nil {
+		j.Errorf("error saving pending children: %v", cErr)
+	} else {
+		cErr = j.syncChildren(ctx)
+	}
+	if err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = j.trackDeletions(ctx, state, write)
+	}
+	return err
+}
+
+func (j *Job) fetch(ctx context.Context, first, last time.Time, write func(*data.Item) error) error {
+	cp, err := j.loadCheckpoint(0)
+	if err != nil {
+		j.Errorf("error loading checkpoint: %v", err)
+		return err
+	}
+
+	if cp != nil {
+		if err := j.resume(ctx, cp, write); err != nil {
 			return err
 		}
-		for v := range ch {
-			if err := v.Err(); err != nil {
-				j.Errorf("error during pagination: %v", err)
+	}
+
+	// an interrupted backfill is finished before anything else; the data
+	// it has written so far says nothing about what is missing
+	manifest, err := j.loadShards()
+	if err != nil {
+		j.Errorf("error loading shard manifest: %v", err)
+		return err
+	}
+	if manifest != nil {
+		return j.backfill(ctx, manifest, write)
+	}
+
+	if first.IsZero() && last.IsZero() {
+		j.Infof("no existing data found, fetching all %s", j.Element)
+		// bulk records miss most of the REST fields, so the export is kept
+		// on the side and only the REST records move the watermark
+		if j.Bulk && j.Resource.BulkQuery != "" {
+			if err := j.bulk(ctx, j.Resource.BulkQuery); err != nil {
+				return err
+			}
+		}
+		if j.Shards > 1 && j.Resource.Countable && !j.DryRun {
+			manifest, err := j.planShards(ctx, j.Shards)
+			if err != nil {
 				return err
 			}
-			results <- v.Item()
+			return j.backfill(ctx, manifest, write)
 		}
+		return j.paginate(ctx, j.newCheckpoint(Window{}), write)
+	}
+
+	j.Infof("fetching all %s before %s", j.Element, fmtTime(first))
+	if err := j.paginate(ctx, j.newCheckpoint(Window{UpdatedAtMax: first}), write); err != nil {
+		return err
+	}
+
+	j.Infof("fetching all %s after %s", j.Element, fmtTime(last))
+	return j.paginate(ctx, j.newCheckpoint(Window{UpdatedAtMin: last}), write)
+}
+
+// resume continues the window of an interrupted run from its checkpoint. If
+// Shopify no longer accepts the saved cursor, the whole window is fetched
+// again.
+func (j *Job) resume(ctx context.Context, cp *Checkpoint, write func(*data.Item) error) error {
+	j.Infof("resuming %s %s from page %d", j.Element, cp.Window, cp.Page+1)
+	err := j.paginate(ctx, cp, write)
+	var resErr shopify.ResponseError
+	if errors.As(err, &resErr) && resErr.Status == http.StatusBadRequest {
+		j.Warnf("checkpoint cursor was rejected (%v); fetching the window again", err)
+		err = j.paginate(ctx, cp.restart(), write)
+	}
+	return err
+}
+
+// paginate writes every record in the checkpoint's window, starting at its
+// page_info cursor if it has one, and saves the checkpoint after each page is
+// written.
+func (j *Job) paginate(ctx context.Context, cp *Checkpoint, write func(*data.Item) error) error {
+	options := cp.Window.options()
+	if j.DryRun {
+		v, _ := query.Values(options)
+		s := v.Encode()
+		j.Warnf("dry run enabled; would have paginated %s with options: %s", j.Element, s)
 		return nil
 	}
 
-	if first.IsZero() && last.IsZero() {
-		j.Infof("no existing data found, fetching all %s", j.Element)
-		if fErr := forward(shopify.ListOptions{Limit: 250}); fErr != nil {
-			if err == nil {
-				err = fErr
+	var it *shopify.Iterator
+	count := -1
+	if cp.PageInfo == "" {
+		it = j.Client.IterateResource(j.Resource, options)
+
+		if j.Resource.Countable {
+			var err error
+			if count, err = it.Count(ctx); err != nil {
+				j.Errorf("error counting %s: %v", j.Element, err)
+				return err
 			}
-			return err
+			j.Infof("expecting %d records", count)
 		}
 	} else {
-		j.Infof("fetching all %s before %s", j.Element, fmtTime(first))
-		if fErr := forward(shopify.ListOptions{UpdatedAtMax: first, Limit: 250}); fErr != nil {
-			if err == nil {
-				err = fErr
-			}
+		it = j.Client.IterateResource(j.Resource, url.Values{
+			"page_info": {cp.PageInfo},
+			"limit":     {strconv.Itoa(pageLimit)},
+		})
+	}
+	defer func() { _ = it.Close() }()
+
+	for it.Next(ctx) {
+		if err := write(it.Item()); err != nil {
 			return err
 		}
+		cp.Records++
 
-		j.Infof("fetching all %s after %s", j.Element, fmtTime(last))
-		if fErr := forward(shopify.ListOptions{UpdatedAtMin: last, Limit: 250}); fErr != nil {
-			if err == nil {
-				err = fErr
+		if it.Buffered() == 0 {
+			cp.Page++
+			cp.PageInfo = it.PageInfo()
+			if cp.PageInfo == "" {
+				continue
+			}
+			if err := j.saveCheckpoint(cp); err != nil {
+				j.Errorf("error saving checkpoint: %v", err)
+				return err
 			}
-			return err
 		}
 	}
 
-	return err
+	if err := it.Err(); err != nil {
+		j.Errorf("error during pagination: %v", err)
+		return err
+	}
+
+	if records := it.Records(); count >= 0 && count != records {
+		j.Warnf("expected %d records but got %d", count, records)
+	}
+
+	if err := j.clearCheckpoint(cp.Shard); err != nil {
+		j.Errorf("error removing checkpoint: %v", err)
+		return err
+	}
+
+	return nil
 }
 
-func (j *Job) getMinMaxUpdatedAt(ctx context.Context) (*os.File, time.Time, time.Time, error) {
-	var first, last time.Time
+// open opens the element's output file for appending and returns the state
+// of its contents, scanning the file only if the saved state is missing or
+// does not match the file.
+func (j *Job) open(ctx context.Context) (*os.File, *State, error) {
 	output := filepath.Join(
 		j.OutputDirectory,
 		j.StoreID,
 		j.Element+".jsonl",
 	)
 
-	j.Infof("scanning %q for oldest and latest updated_at timestamp", output)
-	fp, err := os.OpenFile(output, os.O_RDWR, 0)
+	// the webhook receiver appends to the same file, so writes must always
+	// go to the end of it rather than to where this run last wrote
+	fp, err := os.OpenFile(output, os.O_RDWR|os.O_APPEND, 0)
 	if err != nil && os.IsNotExist(err) {
 		j.Infof("%q does not exist, creating a new file", output)
 		_ = os.MkdirAll(path.Dir(output), 0777)
-		fp, err = os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
-		return fp, first, last, err
+		fp, err = os.OpenFile(output, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+		return fp, &State{Element: j.Element}, err
 	}
 	if err != nil {
-		return fp, first, last, err
+		return fp, nil, err
 	}
 
-	seen := 0
-	r := data.NewReader(fp)
-	for r.Scan() {
-		select {
-		case <-ctx.Done():
-			_ = fp.Close()
-			return nil, first, last, ctx.Err()
-		default:
-		}
+	state, err := j.loadState()
+	if err != nil {
+		j.Warnf("error loading state: %v", err)
+	}
 
-		ts := r.Item().UpdatedAt
+	size, err := fp.Seek(0, io.SeekEnd)
+	if err != nil {
+		_ = fp.Close()
+		return nil, nil, err
+	}
 
-		if first.IsZero() || ts.Before(first) {
-			first = ts
-		}
+	if state != nil && state.Offset == size {
+		j.Infof(
+			"using saved state for %q: %d records, oldest %s, newest %s",
+			output,
+			state.Records,
+			fmtTime(state.First),
+			fmtTime(state.Last),
+		)
+		return fp, state, nil
+	}
 
-		if last.IsZero() || ts.After(last) {
-			last = ts
-		}
+	saved := state
+	if saved != nil {
+		j.Infof("saved state for %q is stale (offset %d, size %d)", output, saved.Offset, size)
+	}
 
-		seen++
+	if _, err := fp.Seek(0, io.SeekStart); err != nil {
+		_ = fp.Close()
+		return nil, nil, err
 	}
 
-	if err := r.Err(); err != nil {
+	j.Infof("scanning %q for oldest and latest updated_at timestamp", output)
+	state, err = j.scan(ctx, fp)
+	if err != nil {
 		_ = fp.Close()
-		return nil, first, last, err
+		return nil, nil, err
+	}
+	if saved != nil {
+		// the saved window came from API fetches only; records scanned
+		// from older files may still widen it
+		state.extend(saved.First)
+		state.extend(saved.Last)
+		state.LastRun = saved.LastRun
+		state.DeletionsCheckedAt = saved.DeletionsCheckedAt
+	}
+
+	// writes always append, but the offset saved with the state is read
+	// back from the file position
+	if _, err := fp.Seek(0, io.SeekEnd); err != nil {
+		_ = fp.Close()
+		return nil, nil, err
+	}
+
+	j.Infof("scanned %d records, oldest %s, newest %s", state.Records, fmtTime(state.First), fmtTime(state.Last))
+	return fp, state, nil
+}
+
+func (j *Job) scan(ctx context.Context, r io.Reader) (*State, error) {
+	state := &State{Element: j.Element}
+
+	dr := data.NewTimestampReader(r, j.Resource.Timestamps)
+	for dr.Scan() {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		state.observe(dr.Item())
+	}
+
+	if err := dr.Err(); err != nil {
+		return nil, err
 	}
 
-	j.Infof("scanned %d records, oldest %s, newest %s", seen, fmtTime(first), fmtTime(last))
-	return fp, first, last, nil
+	return state, nil
 }
 
 func fmtTime(t time.Time) string {
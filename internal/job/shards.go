@@ -0,0 +1,223 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/demosdemon/shop/pkg/data"
+)
+
+// shopifyEpoch is earlier than any record's created_at.
+var shopifyEpoch = time.Date(2006, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Shards is the plan of a sharded backfill. It is saved when the backfill
+// starts and after every shard that completes, and removed once they all
+// have, so an interrupted backfill only repeats its unfinished shards.
+type Shards struct {
+	Element   string    `json:"element"`
+	CreatedAt time.Time `json:"created_at"`
+	Shards    []Shard   `json:"shards"`
+}
+
+// Shard is a created_at window of a backfill. Shards do not overlap.
+type Shard struct {
+	Index    int    `json:"index"`
+	Window   Window `json:"window"`
+	Expected int    `json:"expected"`
+	Done     bool   `json:"done"`
+}
+
+func (j *Job) shardsPath() string {
+	return filepath.Join(j.OutputDirectory, j.StoreID, j.Element+".shards.json")
+}
+
+// loadShards returns the saved shard manifest, or nil if there is none.
+func (j *Job) loadShards() (*Shards, error) {
+	buf, err := ioutil.ReadFile(j.shardsPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(Shards)
+	if err := json.Unmarshal(buf, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (j *Job) saveShards(s *Shards) error {
+	return writeFileAtomic(j.shardsPath(), s)
+}
+
+func (j *Job) clearShards() error {
+	err := os.Remove(j.shardsPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// planShards splits the element's records into n created_at windows of about
+// the same size by bisecting the count of records created before each
+// boundary. The last window is unbounded so records created during the
+// backfill are not missed.
+func (j *Job) planShards(ctx context.Context, n int) (*Shards, error) {
+	start := time.Now().UTC().Truncate(time.Second)
+	total, err := j.countCreated(ctx, start)
+	if err != nil {
+		j.Errorf("error counting %s: %v", j.Element, err)
+		return nil, err
+	}
+
+	manifest := &Shards{Element: j.Element, CreatedAt: start}
+	if total < n*pageLimit {
+		// not worth splitting
+		n = 1
+	}
+
+	j.Infof("splitting %d %s into %d shards", total, j.Element, n)
+	lo, prev := shopifyEpoch, 0
+	var min time.Time
+	for idx := 1; idx <= n; idx++ {
+		shard := Shard{Index: idx, Window: Window{CreatedAtMin: min}}
+
+		if idx == n {
+			shard.Expected = total - prev
+		} else {
+			boundary, count, err := j.bisect(ctx, lo, start, total*idx/n, total/n/100)
+			if err != nil {
+				j.Errorf("error finding shard boundary: %v", err)
+				return nil, err
+			}
+
+			shard.Window.CreatedAtMax = boundary
+			shard.Expected = count - prev
+			lo, prev = boundary, count
+			min = boundary.Add(time.Second)
+		}
+
+		j.Debugf("shard %d/%d: %s, about %d records", idx, n, shard.Window, shard.Expected)
+		manifest.Shards = append(manifest.Shards, shard)
+	}
+
+	if err := j.saveShards(manifest); err != nil {
+		j.Errorf("error saving shard manifest: %v", err)
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// bisect returns the earliest second in (lo, hi] by which about target
+// records were created, within tolerance, and the count at that second.
+func (j *Job) bisect(ctx context.Context, lo, hi time.Time, target, tolerance int) (time.Time, int, error) {
+	count := -1
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2).Truncate(time.Second)
+		n, err := j.countCreated(ctx, mid)
+		if err != nil {
+			return time.Time{}, 0, err
+		}
+
+		if n >= target {
+			hi, count = mid, n
+		} else {
+			lo = mid
+		}
+
+		if d := n - target; -tolerance <= d && d <= tolerance {
+			return mid, n, nil
+		}
+	}
+
+	if count < 0 {
+		var err error
+		if count, err = j.countCreated(ctx, hi); err != nil {
+			return time.Time{}, 0, err
+		}
+	}
+	return hi, count, nil
+}
+
+func (j *Job) countCreated(ctx context.Context, max time.Time) (int, error) {
+	it := j.Client.IterateResource(j.Resource, Window{CreatedAtMax: max}.options())
+	return it.Count(ctx)
+}
+
+// backfill fetches the unfinished shards of the manifest concurrently. The
+// requests share the client's rate limiter, so they stay within the store's
+// budget, and the records are merged into the output file through write. A
+// failed shard does not stop the others.
+func (j *Job) backfill(ctx context.Context, manifest *Shards, write func(*data.Item) error) error {
+	var mu sync.Mutex
+	locked := func(item *data.Item) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return write(item)
+	}
+
+	var wg sync.WaitGroup
+	var errs error
+	for idx := range manifest.Shards {
+		shard := &manifest.Shards[idx]
+		if shard.Done {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := j.fetchShard(ctx, len(manifest.Shards), shard, locked)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				j.Errorf("shard %d failed: %v", shard.Index, err)
+				errs = multierror.Append(errs, err)
+				return
+			}
+
+			shard.Done = true
+			if err := j.saveShards(manifest); err != nil {
+				j.Errorf("error saving shard manifest: %v", err)
+				errs = multierror.Append(errs, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if errs != nil {
+		return errs
+	}
+
+	j.Infof("backfill of %d shards complete", len(manifest.Shards))
+	return j.clearShards()
+}
+
+func (j *Job) fetchShard(ctx context.Context, n int, shard *Shard, write func(*data.Item) error) error {
+	cp, err := j.loadCheckpoint(shard.Index)
+	if err != nil {
+		return err
+	}
+
+	if cp != nil {
+		return j.resume(ctx, cp, write)
+	}
+
+	j.Infof("fetching shard %d/%d: %s", shard.Index, n, shard.Window)
+	cp = j.newCheckpoint(shard.Window)
+	cp.Shard = shard.Index
+	return j.paginate(ctx, cp, write)
+}
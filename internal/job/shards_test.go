@@ -0,0 +1,192 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/demosdemon/shop/pkg/data"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+// failLaterShards fails the listing of every shard but the first, which is
+// the only one without a lower bound.
+type failLaterShards struct {
+	http.RoundTripper
+}
+
+func (f failLaterShards) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/orders.json") && req.URL.Query().Get("created_at_min") != "" {
+		return nil, errors.New("connection reset")
+	}
+	return f.RoundTripper.RoundTrip(req)
+}
+
+// newShardServer returns a server with records 1..total and a bucket large
+// enough for the many counts of planning shards.
+func newShardServer(t *testing.T, total int64) *shopifytest.Server {
+	t.Helper()
+	s := shopifytest.NewServer()
+	t.Cleanup(s.Close)
+	s.BucketSize = 10000
+	s.LeakRate = 500
+	seed(t, s, "orders", 1, total)
+	return s
+}
+
+// newShardJob returns a job whose store directory exists, as it does once
+// the output file has been opened.
+func newShardJob(t *testing.T, s *shopifytest.Server, dir string) *Job {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, storeID), 0777); err != nil {
+		t.Fatal(err)
+	}
+	return newJob(t, s, dir, "orders")
+}
+
+func TestPlanShards(t *testing.T) {
+	const total, n = 4 * pageLimit, 4
+	s := newShardServer(t, total)
+	j := newShardJob(t, s, tempDir(t))
+	manifest, err := j.planShards(context.Background(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Shards) != n {
+		t.Fatalf("planned %d shards, want %d", len(manifest.Shards), n)
+	}
+
+	tolerance := total / n / 100
+	sum := 0
+	for idx, shard := range manifest.Shards {
+		// both of a shard's boundaries may be off by the tolerance
+		sum += shard.Expected
+		if d := shard.Expected - total/n; d < -2*tolerance || d > 2*tolerance {
+			t.Errorf("shard %d expects %d records, want %d ± %d", shard.Index, shard.Expected, total/n, 2*tolerance)
+		}
+
+		// each boundary is counted again against the tolerance
+		if idx < n-1 {
+			count, err := j.countCreated(context.Background(), shard.Window.CreatedAtMax)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := count - total*(idx+1)/n; d < -tolerance || d > tolerance {
+				t.Errorf("shard %d ends after %d records, want %d ± %d", shard.Index, count, total*(idx+1)/n, tolerance)
+			}
+		}
+
+		if idx > 0 {
+			if want := manifest.Shards[idx-1].Window.CreatedAtMax.Add(time.Second); !shard.Window.CreatedAtMin.Equal(want) {
+				t.Errorf("shard %d starts at %s, want %s", shard.Index, shard.Window.CreatedAtMin, want)
+			}
+		}
+	}
+	if sum != total {
+		t.Errorf("shards expect %d records in all, want %d", sum, total)
+	}
+
+	// records created during the backfill fall in the last shard
+	if last := manifest.Shards[n-1].Window; !last.CreatedAtMax.IsZero() {
+		t.Errorf("last shard ends at %s, want it unbounded", last.CreatedAtMax)
+	}
+	if !exists(t, j.shardsPath()) {
+		t.Error("shard manifest was not saved")
+	}
+}
+
+func TestPlanShardsNotWorthSplitting(t *testing.T) {
+	s := newShardServer(t, 10)
+	manifest, err := newShardJob(t, s, tempDir(t)).planShards(context.Background(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Shards) != 1 || manifest.Shards[0].Expected != 10 || manifest.Shards[0].Window != (Window{}) {
+		t.Errorf("shards = %+v, want one unbounded shard of 10", manifest.Shards)
+	}
+}
+
+func TestBackfillSerializesWrites(t *testing.T) {
+	const total = 4 * pageLimit
+	s := newShardServer(t, total)
+	j := newShardJob(t, s, tempDir(t))
+	manifest, err := j.planShards(context.Background(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var writing, overlaps int32
+	var items []*data.Item
+	write := func(item *data.Item) error {
+		if atomic.AddInt32(&writing, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		runtime.Gosched()
+		items = append(items, item.Clone())
+		atomic.AddInt32(&writing, -1)
+		return nil
+	}
+
+	if err := j.backfill(context.Background(), manifest, write); err != nil {
+		t.Fatal(err)
+	}
+	if overlaps != 0 {
+		t.Errorf("%d writes overlapped", overlaps)
+	}
+	checkAllIDs(t, items, 1, total)
+	if len(items) != total {
+		t.Errorf("wrote %d records, want %d", len(items), total)
+	}
+	if exists(t, j.shardsPath()) {
+		t.Error("shard manifest was not removed after the backfill")
+	}
+}
+
+func TestBackfillResumesUnfinishedShards(t *testing.T) {
+	const total = 3 * pageLimit
+	s := newShardServer(t, total)
+	dir := tempDir(t)
+
+	j := newJob(t, s, dir, "orders")
+	j.Shards = 2
+	j.Client.Transport = failLaterShards{http.DefaultTransport}
+	if err := j.Do(context.Background()); err == nil {
+		t.Fatal("Do() succeeded with a failing shard")
+	}
+
+	manifest, err := j.loadShards()
+	if err != nil || manifest == nil {
+		t.Fatalf("loadShards() = %v, %v", manifest, err)
+	}
+	if !manifest.Shards[0].Done || manifest.Shards[1].Done {
+		t.Fatalf("shards done = %t, %t; want only the first", manifest.Shards[0].Done, manifest.Shards[1].Done)
+	}
+	first := len(readItems(t, dir, "orders.jsonl"))
+	if first != manifest.Shards[0].Expected {
+		t.Errorf("failed run wrote %d records, want the first shard's %d", first, manifest.Shards[0].Expected)
+	}
+
+	// the next run fetches the second shard only, even without -shards
+	j = newJob(t, s, dir, "orders")
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	items := readItems(t, dir, "orders.jsonl")
+	checkAllIDs(t, items, 1, total)
+	for id, n := range idSet(items) {
+		if n != 1 {
+			t.Fatalf("id %d was written %d times", id, n)
+		}
+	}
+	if exists(t, j.shardsPath()) {
+		t.Error("shard manifest was not removed after the backfill")
+	}
+}
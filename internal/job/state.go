@@ -0,0 +1,82 @@
+package job
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/demosdemon/shop/pkg/data"
+)
+
+// State summarizes an element's output file so the next run can skip
+// scanning it. It is only trusted while Offset matches the size of the file.
+// First and Last only cover records fetched from the API; records delivered
+// by webhooks share the file but say nothing about what has been fetched.
+type State struct {
+	Element string    `json:"element"`
+	First   time.Time `json:"first_updated_at"`
+	Last    time.Time `json:"last_updated_at"`
+	Records int       `json:"records"`
+	Offset  int64     `json:"offset"`
+	LastRun time.Time `json:"last_run"`
+
+	// DeletionsCheckedAt is when deleted records were last looked for, in
+	// the events resource or by reconciling ids.
+	DeletionsCheckedAt time.Time `json:"deletions_checked_at"`
+}
+
+// observe adds item to the state. Tombstones and webhook records are counted
+// but do not move the updated_at window: a tombstone's timestamp is the
+// deletion time, and a webhook record may be newer than records that have
+// not been fetched yet.
+func (s *State) observe(item *data.Item) {
+	s.Records++
+	if item.Deleted || item.Webhook {
+		return
+	}
+
+	s.extend(item.UpdatedAt)
+}
+
+// extend widens the updated_at window to include ts.
+func (s *State) extend(ts time.Time) {
+	if ts.IsZero() {
+		return
+	}
+
+	if s.First.IsZero() || ts.Before(s.First) {
+		s.First = ts
+	}
+
+	if s.Last.IsZero() || ts.After(s.Last) {
+		s.Last = ts
+	}
+}
+
+func (j *Job) statePath() string {
+	return filepath.Join(j.OutputDirectory, j.StoreID, j.Element+".state.json")
+}
+
+// loadState returns the saved state, or nil if there is none.
+func (j *Job) loadState() (*State, error) {
+	buf, err := ioutil.ReadFile(j.statePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(State)
+	if err := json.Unmarshal(buf, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (j *Job) saveState(s *State) error {
+	return writeFileAtomic(j.statePath(), s)
+}
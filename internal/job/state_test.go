@@ -0,0 +1,177 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/demosdemon/shop/pkg/data"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+func TestInitialAndIncrementalSync(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	dir := tempDir(t)
+	seed(t, s, "orders", 1, 5)
+
+	if err := newJob(t, s, dir, "orders").Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	items := readItems(t, dir, "orders.jsonl")
+	if len(items) != 5 {
+		t.Fatalf("initial sync wrote %d records, want 5", len(items))
+	}
+	checkAllIDs(t, items, 1, 5)
+
+	j := newJob(t, s, dir, "orders")
+	state, err := j.loadState()
+	if err != nil || state == nil {
+		t.Fatalf("loadState() = %v, %v", state, err)
+	}
+	if want := epoch.Add(time.Minute); !state.First.Equal(want) {
+		t.Errorf("First = %s, want %s", state.First, want)
+	}
+	if want := epoch.Add(5 * time.Minute); !state.Last.Equal(want) {
+		t.Errorf("Last = %s, want %s", state.Last, want)
+	}
+	if state.Records != 5 || state.LastRun.IsZero() {
+		t.Errorf("Records = %d, LastRun = %s", state.Records, state.LastRun)
+	}
+
+	seed(t, s, "orders", 6, 7)
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the window bounds are inclusive, so the oldest and newest records are
+	// fetched again along with the new ones
+	items = readItems(t, dir, "orders.jsonl")
+	checkAllIDs(t, items, 1, 7)
+	if ids := idSet(items); ids[3] != 1 {
+		t.Errorf("record 3 was written %d times, want once", ids[3])
+	}
+
+	state, err = j.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := epoch.Add(7 * time.Minute); !state.Last.Equal(want) {
+		t.Errorf("Last = %s, want %s", state.Last, want)
+	}
+	if state.Records != len(items) {
+		t.Errorf("Records = %d, want %d", state.Records, len(items))
+	}
+	info, err := os.Stat(filepath.Join(dir, storeID, "orders.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Offset != info.Size() {
+		t.Errorf("Offset = %d, want the file size %d", state.Offset, info.Size())
+	}
+}
+
+func TestStaleStateIsRescanned(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	dir := tempDir(t)
+	seed(t, s, "orders", 1, 3)
+
+	if err := newJob(t, s, dir, "orders").Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// another process appends to the file behind the saved state's back
+	fp, err := os.OpenFile(filepath.Join(dir, storeID, "orders.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, _ := json.Marshal(newRecord(9, 90))
+	if _, err := fp.Write(append(buf, '\n')); err != nil {
+		t.Fatal(err)
+	}
+	if err := fp.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	j := newJob(t, s, dir, "orders")
+	fp, state, err := j.open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = fp.Close() }()
+
+	if state.Records != 4 {
+		t.Errorf("Records = %d, want 4", state.Records)
+	}
+	if want := epoch.Add(90 * time.Minute); !state.Last.Equal(want) {
+		t.Errorf("Last = %s, want %s", state.Last, want)
+	}
+	if state.LastRun.IsZero() {
+		t.Error("LastRun of the saved state was dropped")
+	}
+
+	info, err := fp.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, _ := fp.Seek(0, io.SeekCurrent); offset != info.Size() {
+		t.Errorf("file is at %d, want the end %d", offset, info.Size())
+	}
+}
+
+func TestWebhookRecordsDoNotMoveWatermark(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	dir := tempDir(t)
+	seed(t, s, "orders", 1, 3)
+
+	if err := newJob(t, s, dir, "orders").Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the webhook receiver appends a record updated long after anything
+	// that has been fetched
+	fp, err := os.OpenFile(filepath.Join(dir, storeID, "orders.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, _ := json.Marshal(newRecord(100, 1000))
+	item := new(data.Item)
+	if err := item.Decode(buf, data.DefaultTimestamps); err != nil {
+		t.Fatal(err)
+	}
+	if err := item.Annotate(data.WebhookField, "orders/updated"); err != nil {
+		t.Fatal(err)
+	}
+	item.Webhook = true
+	if err := data.NewLockedWriter(fp).Write(item); err != nil {
+		t.Fatal(err)
+	}
+	if err := fp.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// an order updated before the webhook's but after the last fetch
+	seed(t, s, "orders", 4, 4)
+
+	j := newJob(t, s, dir, "orders")
+	if err := j.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	checkAllIDs(t, readItems(t, dir, "orders.jsonl"), 1, 4)
+
+	state, err := j.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := epoch.Add(4 * time.Minute); !state.Last.Equal(want) {
+		t.Errorf("Last = %s, want %s", state.Last, want)
+	}
+}
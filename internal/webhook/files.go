@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/demosdemon/shop/pkg/data"
+)
+
+// Files appends records to per-store element files. Each file is opened once
+// in append mode and every record is written under a file lock, so neither
+// concurrent webhooks nor a sync run appending to the same file interleave
+// records. Sync state is left stale by these writes and rescanned on the next
+// run; the records are marked with data.WebhookField so the rescan does not
+// move the sync's watermark.
+type Files struct {
+	dir string
+
+	mu    sync.Mutex
+	files map[string]*file
+}
+
+type file struct {
+	mu sync.Mutex
+	fp *os.File
+	w  *data.LockedWriter
+}
+
+func NewFiles(dir string) *Files {
+	return &Files{dir: dir, files: make(map[string]*file)}
+}
+
+// Write appends item to `<dir>/<storeID>/<element>.jsonl`.
+func (f *Files) Write(storeID, element string, item *data.Item) error {
+	fp, err := f.open(filepath.Join(f.dir, storeID, element+".jsonl"))
+	if err != nil {
+		return err
+	}
+
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	return fp.w.Write(item)
+}
+
+func (f *Files) open(path string) (*file, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if fp, ok := f.files[path]; ok {
+		return fp, nil
+	}
+
+	_ = os.MkdirAll(filepath.Dir(path), 0777)
+	fp, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &file{fp: fp, w: data.NewLockedWriter(fp)}
+	f.files[path] = rv
+	return rv, nil
+}
+
+// Close closes every open file.
+func (f *Files) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var err error
+	for path, fp := range f.files {
+		fp.mu.Lock()
+		if cErr := fp.fp.Close(); cErr != nil {
+			err = multierror.Append(err, cErr)
+		}
+		fp.mu.Unlock()
+		delete(f.files, path)
+	}
+	return err
+}
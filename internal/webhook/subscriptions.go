@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+// DefaultTopics are the topics the receiver writes to element files.
+var DefaultTopics = []string{
+	"orders/create",
+	"orders/updated",
+	"orders/delete",
+	"products/create",
+	"products/update",
+	"products/delete",
+	"customers/create",
+	"customers/update",
+	"customers/delete",
+}
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Change is a single step that brings a store's subscriptions to the desired
+// state.
+type Change struct {
+	Action  Action
+	Topic   string
+	Address string
+
+	// ID and OldAddress describe the existing subscription for updates and
+	// deletes.
+	ID         int64
+	OldAddress string
+}
+
+func (c Change) String() string {
+	switch c.Action {
+	case ActionCreate:
+		return fmt.Sprintf("+ %s %s", c.Topic, c.Address)
+	case ActionUpdate:
+		return fmt.Sprintf("~ %s %s -> %s", c.Topic, c.OldAddress, c.Address)
+	case ActionDelete:
+		return fmt.Sprintf("- %s %s", c.Topic, c.OldAddress)
+	default:
+		return fmt.Sprintf("? %s", c.Topic)
+	}
+}
+
+// Plan returns the changes that subscribe each of store's topics to address
+// exactly once. An existing subscription to another address is moved rather
+// than replaced, and every other subscription is deleted.
+func Plan(existing []shopify.Webhook, topics []string, address string) []Change {
+	byTopic := make(map[string][]shopify.Webhook)
+	for _, webhook := range existing {
+		byTopic[webhook.Topic] = append(byTopic[webhook.Topic], webhook)
+	}
+
+	var changes []Change
+	desired := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		if desired[topic] {
+			continue
+		}
+		desired[topic] = true
+
+		webhooks := byTopic[topic]
+		keep := -1
+		for idx, webhook := range webhooks {
+			if webhook.Address == address {
+				keep = idx
+				break
+			}
+		}
+
+		switch {
+		case keep >= 0:
+		case len(webhooks) > 0:
+			keep = 0
+			changes = append(changes, Change{
+				Action:     ActionUpdate,
+				Topic:      topic,
+				Address:    address,
+				ID:         webhooks[0].ID,
+				OldAddress: webhooks[0].Address,
+			})
+		default:
+			changes = append(changes, Change{
+				Action:  ActionCreate,
+				Topic:   topic,
+				Address: address,
+			})
+		}
+
+		for idx, webhook := range webhooks {
+			if idx != keep {
+				changes = append(changes, deleteChange(webhook))
+			}
+		}
+	}
+
+	for topic, webhooks := range byTopic {
+		if desired[topic] {
+			continue
+		}
+		for _, webhook := range webhooks {
+			changes = append(changes, deleteChange(webhook))
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Topic < changes[j].Topic
+	})
+	return changes
+}
+
+func deleteChange(webhook shopify.Webhook) Change {
+	return Change{
+		Action:     ActionDelete,
+		Topic:      webhook.Topic,
+		ID:         webhook.ID,
+		OldAddress: webhook.Address,
+	}
+}
+
+// Apply makes the changes with client, stopping at the first error.
+func Apply(ctx context.Context, client *shopify.Client, changes []Change) error {
+	for _, c := range changes {
+		var err error
+		switch c.Action {
+		case ActionCreate:
+			_, err = client.Webhooks.Create(ctx, shopify.Webhook{Topic: c.Topic, Address: c.Address, Format: "json"})
+		case ActionUpdate:
+			_, err = client.Webhooks.Update(ctx, shopify.Webhook{ID: c.ID, Address: c.Address})
+		case ActionDelete:
+			err = client.Webhooks.Delete(ctx, c.ID)
+		default:
+			err = errors.Errorf("unknown action: %s", c.Action)
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "error applying %s", c)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+func TestPlan(t *testing.T) {
+	const (
+		address = "https://hooks.example.com/webhook"
+		old     = "https://old.example.com/webhook"
+	)
+
+	tests := []struct {
+		name     string
+		existing []shopify.Webhook
+		topics   []string
+		want     []Change
+	}{
+		{
+			name:   "create",
+			topics: []string{"orders/create", "orders/delete"},
+			want: []Change{
+				{Action: ActionCreate, Topic: "orders/create", Address: address},
+				{Action: ActionCreate, Topic: "orders/delete", Address: address},
+			},
+		},
+		{
+			name:     "update",
+			existing: []shopify.Webhook{{ID: 1, Topic: "orders/create", Address: old}},
+			topics:   []string{"orders/create"},
+			want: []Change{
+				{Action: ActionUpdate, Topic: "orders/create", Address: address, ID: 1, OldAddress: old},
+			},
+		},
+		{
+			name: "delete",
+			existing: []shopify.Webhook{
+				{ID: 1, Topic: "orders/create", Address: address},
+				{ID: 2, Topic: "orders/create", Address: old},
+				{ID: 3, Topic: "carts/update", Address: address},
+			},
+			topics: []string{"orders/create"},
+			want: []Change{
+				{Action: ActionDelete, Topic: "carts/update", ID: 3, OldAddress: address},
+				{Action: ActionDelete, Topic: "orders/create", ID: 2, OldAddress: old},
+			},
+		},
+		{
+			name: "no-op",
+			existing: []shopify.Webhook{
+				{ID: 1, Topic: "orders/create", Address: address},
+				{ID: 2, Topic: "orders/delete", Address: address},
+			},
+			// duplicate topics are subscribed once
+			topics: []string{"orders/delete", "orders/create", "orders/delete"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Plan(tt.existing, tt.topics, address)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Plan() = %v, want %v", got, tt.want)
+			}
+			for idx := range tt.want {
+				if got[idx] != tt.want[idx] {
+					t.Errorf("change %d = %+v, want %+v", idx, got[idx], tt.want[idx])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// Package webhook receives Shopify webhooks and appends their payloads to the
+// same per-store element files that a sync writes.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/demosdemon/shop/internal/config"
+	"github.com/demosdemon/shop/pkg/data"
+	"github.com/demosdemon/shop/pkg/log"
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+const (
+	HeaderHmac        = "X-Shopify-Hmac-Sha256"
+	HeaderShopDomain  = "X-Shopify-Shop-Domain"
+	HeaderTopic       = "X-Shopify-Topic"
+	HeaderTriggeredAt = "X-Shopify-Triggered-At"
+
+	// MaxPayloadSize bounds the request bodies the handler reads.
+	MaxPayloadSize = 10 << 20
+
+	shopDomainSuffix = ".myshopify.com"
+)
+
+// Handler verifies webhooks and appends their payloads to
+// `<output>/<store>/<element>.jsonl`. Payloads of delete topics are written
+// as tombstones.
+type Handler struct {
+	log.Logger
+
+	stores map[string]*config.Store
+	files  *Files
+}
+
+// NewHandler returns a handler for webhooks from the given stores. Stores
+// without a webhook secret are ignored.
+func NewHandler(logger log.Logger, files *Files, stores []*config.Store) *Handler {
+	h := &Handler{
+		Logger: logger,
+		stores: make(map[string]*config.Store, len(stores)),
+		files:  files,
+	}
+
+	for _, store := range stores {
+		if store.WebhookSecret == "" {
+			logger.Warnf("store %s has no webhook secret; ignoring its webhooks", store.StoreID)
+			continue
+		}
+		h.stores[store.StoreID] = store
+	}
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	domain := r.Header.Get(HeaderShopDomain)
+	topic := r.Header.Get(HeaderTopic)
+	store, ok := h.stores[strings.TrimSuffix(domain, shopDomainSuffix)]
+	if !ok {
+		h.Warnf("webhook %s from unknown shop %q", topic, domain)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxPayloadSize))
+	if err != nil {
+		h.Errorf("error reading webhook %s from %s: %v", topic, store.StoreID, err)
+		// the reader fails having read exactly the limit once the body is
+		// larger
+		status := http.StatusBadRequest
+		if len(body) == MaxPayloadSize {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	if !Verify(store.WebhookSecret, body, r.Header.Get(HeaderHmac)) {
+		h.Warnf("webhook %s from %s failed verification", topic, store.StoreID)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	element, action := splitTopic(topic)
+	resource, ok := shopify.LookupResource(element)
+	if !ok || resource.Parent != "" {
+		// acknowledge it anyway, or Shopify keeps retrying and eventually
+		// removes the subscription
+		h.Debugf("ignoring webhook %s from %s", topic, store.StoreID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	item, err := decode(r, body, action, resource)
+	if err != nil {
+		h.Errorf("error decoding webhook %s from %s: %v", topic, store.StoreID, err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	// sync runs must not take the record's updated_at as their watermark,
+	// or records updated in between would never be fetched
+	if err := item.Annotate(data.WebhookField, topic); err != nil {
+		h.Errorf("error annotating webhook %s from %s: %v", topic, store.StoreID, err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	item.Webhook = true
+
+	if err := h.files.Write(store.StoreID, element, item); err != nil {
+		h.Errorf("error writing webhook %s from %s: %v", topic, store.StoreID, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	h.Debugf("wrote %s %d from %s", topic, item.ID, store.StoreID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Verify reports whether signature is the base64 encoded HMAC-SHA256 of body
+// keyed with secret.
+func Verify(secret string, body []byte, signature string) bool {
+	expected, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(digest(secret, body), expected)
+}
+
+// Sign returns the signature Shopify sends for body.
+func Sign(secret string, body []byte) string {
+	return base64.StdEncoding.EncodeToString(digest(secret, body))
+}
+
+func digest(secret string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// splitTopic splits a topic like `orders/updated` into its element and
+// action.
+func splitTopic(topic string) (element, action string) {
+	idx := strings.IndexByte(topic, '/')
+	if idx < 0 {
+		return topic, ""
+	}
+	return topic[:idx], topic[idx+1:]
+}
+
+func decode(r *http.Request, body []byte, action string, resource *shopify.Resource) (*data.Item, error) {
+	if action != "delete" {
+		item := new(data.Item)
+		if err := item.Decode(body, resource.Timestamps); err != nil {
+			return nil, err
+		}
+		return item, nil
+	}
+
+	// delete payloads only carry the id
+	id := gjson.GetBytes(body, "id").Int()
+	if id == 0 {
+		return nil, errors.New("delete payload has no id")
+	}
+
+	deletedAt := time.Now()
+	if t, err := time.Parse(time.RFC3339, r.Header.Get(HeaderTriggeredAt)); err == nil {
+		deletedAt = t
+	}
+
+	return data.NewTombstone(id, deletedAt), nil
+}
@@ -0,0 +1,186 @@
+package webhook
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/demosdemon/shop/internal/config"
+	"github.com/demosdemon/shop/pkg/data"
+	"github.com/demosdemon/shop/pkg/log"
+)
+
+const (
+	storeID = "test-store"
+	secret  = "hush"
+)
+
+type testWriter struct {
+	t *testing.T
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(string(bytes.TrimRight(p, "\n")))
+	return len(p), nil
+}
+
+func newHandler(t *testing.T) (*Handler, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := NewFiles(dir)
+	t.Cleanup(func() {
+		_ = files.Close()
+		_ = os.RemoveAll(dir)
+	})
+
+	logger := log.NewLogger(log.LevelDebug, testWriter{t}, "")
+	stores := []*config.Store{
+		{StoreID: storeID, WebhookSecret: secret},
+		{StoreID: "no-secret"},
+	}
+	return NewHandler(logger, files, stores), dir
+}
+
+func post(h *Handler, domain, topic, signature string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set(HeaderShopDomain, domain)
+	req.Header.Set(HeaderTopic, topic)
+	req.Header.Set(HeaderHmac, signature)
+	req.Header.Set(HeaderTriggeredAt, "2020-01-02T03:04:05Z")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// readLines returns the lines of the store's element file, if it exists.
+func readLines(t *testing.T, dir, element string) [][]byte {
+	t.Helper()
+	buf, err := ioutil.ReadFile(filepath.Join(dir, storeID, element+".jsonl"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Split(bytes.TrimSuffix(buf, []byte("\n")), []byte("\n"))
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	signature := Sign(secret, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{name: "valid", secret: secret, body: body, signature: signature, want: true},
+		{name: "tampered body", secret: secret, body: []byte(`{"id":2}`), signature: signature},
+		{name: "other secret", secret: "other", body: body, signature: signature},
+		{name: "not base64", secret: secret, body: body, signature: "not base64!"},
+		{name: "empty", secret: secret, body: body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.body, tt.signature); got != tt.want {
+				t.Errorf("Verify() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeHTTPWritesAnnotatedRecord(t *testing.T) {
+	h, dir := newHandler(t)
+	body := []byte(`{"id":42,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-02T00:00:00Z"}`)
+
+	rec := post(h, storeID+shopDomainSuffix, "orders/updated", Sign(secret, body), body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	lines := readLines(t, dir, "orders")
+	if len(lines) != 1 {
+		t.Fatalf("wrote %d records, want 1", len(lines))
+	}
+	if id := gjson.GetBytes(lines[0], "id").Int(); id != 42 {
+		t.Errorf("id = %d, want 42", id)
+	}
+	if topic := gjson.GetBytes(lines[0], data.WebhookField).String(); topic != "orders/updated" {
+		t.Errorf("%s = %q, want orders/updated", data.WebhookField, topic)
+	}
+}
+
+func TestServeHTTPWritesTombstoneForDelete(t *testing.T) {
+	h, dir := newHandler(t)
+	body := []byte(`{"id":42}`)
+
+	rec := post(h, storeID+shopDomainSuffix, "products/delete", Sign(secret, body), body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	lines := readLines(t, dir, "products")
+	if len(lines) != 1 {
+		t.Fatalf("wrote %d records, want 1", len(lines))
+	}
+
+	item := new(data.Item)
+	if err := item.Decode(lines[0], data.DefaultTimestamps); err != nil {
+		t.Fatal(err)
+	}
+	if item.ID != 42 || !item.Deleted || !item.Webhook {
+		t.Errorf("item = %d, deleted %t, webhook %t; want a webhook tombstone for 42", item.ID, item.Deleted, item.Webhook)
+	}
+	if got := item.UpdatedAt.Format(time.RFC3339); got != "2020-01-02T03:04:05Z" {
+		t.Errorf("deleted at %s, want the time the webhook was triggered", got)
+	}
+}
+
+func TestServeHTTPRejects(t *testing.T) {
+	body := []byte(`{"id":42,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-02T00:00:00Z"}`)
+
+	tests := []struct {
+		name      string
+		domain    string
+		signature string
+		body      []byte
+		want      int
+	}{
+		{name: "invalid signature", domain: storeID + shopDomainSuffix, signature: Sign("other", body), body: body, want: http.StatusUnauthorized},
+		{name: "unknown shop", domain: "unknown" + shopDomainSuffix, signature: Sign(secret, body), body: body, want: http.StatusUnauthorized},
+		{name: "store without secret", domain: "no-secret" + shopDomainSuffix, signature: Sign("", body), body: body, want: http.StatusUnauthorized},
+		{
+			name:   "oversize payload",
+			domain: storeID + shopDomainSuffix,
+			body:   bytes.Repeat([]byte(" "), MaxPayloadSize+1),
+			want:   http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, dir := newHandler(t)
+			if rec := post(h, tt.domain, "orders/updated", tt.signature, tt.body); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if lines := readLines(t, dir, "orders"); len(lines) != 0 {
+				t.Errorf("wrote %d records", len(lines))
+			}
+		})
+	}
+}
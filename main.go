@@ -6,23 +6,20 @@ import (
 	"fmt"
 	_log "log"
 	"os"
+	"path/filepath"
 	"syscall"
 
-	"github.com/demosdemon/multierrgroup"
+	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 
 	"github.com/demosdemon/shop/internal/config"
 	"github.com/demosdemon/shop/internal/job"
 	"github.com/demosdemon/shop/pkg/log"
+	"github.com/demosdemon/shop/pkg/pool"
+	"github.com/demosdemon/shop/pkg/retry"
 	"github.com/demosdemon/shop/pkg/shopify"
 )
 
-var elements = []string{
-	"orders",
-	"products",
-	"customers",
-}
-
 func main() {
 	var cfg config.Runtime
 	if err := cfg.ParseArgs(os.Args); err != nil {
@@ -44,45 +41,134 @@ func main() {
 
 	go cfg.PeriodicallyPrintStackDump(ctx)
 
-	var g multierrgroup.Group
+	type task struct {
+		store   *config.Store
+		breaker *retry.Breaker
+	}
+
+	// results are in the order the tasks were submitted
+	var tasks []task
+	p := pool.New(ctx, pool.WithConcurrency(cfg.Concurrency))
 	for store := range ch {
-		g.Go(do(ctx, store, &cfg))
+		breaker := newBreaker(store, &cfg)
+		tasks = append(tasks, task{store: store, breaker: breaker})
+		p.Go(store.StoreID, do(store, &cfg, breaker))
 	}
+	results, _ := p.Wait()
 
-	if err := g.Wait(); err != nil {
-		_log.Fatal(err)
+	var r report
+	for idx, result := range results {
+		r.add(tasks[idx].store, tasks[idx].breaker, result)
 	}
+
+	r.print()
+	cancel()
+	os.Exit(r.exitCode())
 }
 
-func do(ctx context.Context, store *config.Store, runtime *config.Runtime) func() error {
-	client := shopify.New(
-		store.StoreID,
-		store.Username,
-		store.Password,
-		shopify.WithAPIVersion(runtime.ShopifyAPIVersion),
-		shopify.WithHTTPTimeout(runtime.HTTPTimeout),
-		shopify.WithRetryCount(runtime.HTTPRetryCount),
-		shopify.WithRetryDelay(runtime.HTTPRetryDelay),
-		shopify.WithRetryJitter(runtime.HTTPRetryJitter),
-		shopify.WithUserAgent(runtime.HTTPUserAgent),
+// newBreaker returns the circuit breaker shared by the store's jobs, or nil if
+// breakers are disabled.
+func newBreaker(store *config.Store, runtime *config.Runtime) *retry.Breaker {
+	if runtime.BreakerThreshold == 0 {
+		return nil
+	}
+
+	// state changes are rare and worth seeing whatever the jobs log, so the
+	// logger lets everything through; its Warnf below is the only place they
+	// are logged
+	prefix := fmt.Sprintf("[%-21s][%-9s] ", store.StoreID, "breaker")
+	logger := log.NewLogger(log.LevelDebug, os.Stderr, prefix)
+	return retry.NewBreaker(
+		runtime.BreakerThreshold,
+		runtime.BreakerCoolDown,
+		retry.WithOnStateChange(func(from, to retry.BreakerState) {
+			logger.Warnf("circuit breaker %s -> %s", from, to)
+		}),
 	)
+}
+
+func do(store *config.Store, runtime *config.Runtime, breaker *retry.Breaker) func(ctx context.Context) error {
+	return func(ctx context.Context) (err error) {
+		options := []shopify.Option{
+			shopify.WithAPIVersion(runtime.ShopifyAPIVersion),
+			shopify.WithHTTPTimeout(runtime.HTTPTimeout),
+			shopify.WithRetryCount(runtime.HTTPRetryCount),
+			shopify.WithRetryBackoff(runtime.HTTPRetryBackoff),
+			shopify.WithRetryMaxElapsed(runtime.HTTPRetryElapsed),
+			shopify.WithAttemptTimeout(runtime.HTTPAttemptTimeout),
+			shopify.WithUserAgent(runtime.HTTPUserAgent),
+			shopify.WithBreaker(breaker),
+		}
+
+		if runtime.RecordDirectory != "" {
+			_ = os.MkdirAll(runtime.RecordDirectory, 0777)
+			recorder, err := shopify.NewRecorder(cassettePath(runtime.RecordDirectory, store))
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if cErr := recorder.Close(); err == nil {
+					err = cErr
+				}
+			}()
+			options = append(options, shopify.WithRecorder(recorder))
+		}
+
+		if runtime.ReplayDirectory != "" {
+			replayer, err := shopify.NewReplayer(cassettePath(runtime.ReplayDirectory, store))
+			if err != nil {
+				return err
+			}
+			options = append(options, shopify.WithReplayer(replayer))
+		}
+
+		client, err := store.NewClient(options...)
+		if err != nil {
+			return err
+		}
+
+		// an element that fails does not stop the others, unless the whole
+		// store is unavailable
+		var errs error
+		for _, element := range runtime.Resources {
+			resource := shopify.ResourceFor(element)
+			if resource.Parent != "" {
+				// synced by the parent's job
+				continue
+			}
+
+			var children []*shopify.Resource
+			for _, child := range shopify.Children(element) {
+				if runtime.HasResource(child.Name) {
+					children = append(children, child)
+				}
+			}
 
-	return func() error {
-		for _, element := range elements {
 			prefix := fmt.Sprintf("[%-21s][%-9s] ", store.StoreID, element)
 			logger := log.NewLogger(log.LevelDebug, os.Stderr, prefix)
 			shopify.WithLogger(logger)(client)
 			j := job.Job{
-				Logger:  logger,
-				Store:   store,
-				Runtime: runtime,
-				Client:  client,
-				Element: element,
+				Logger:   logger,
+				Store:    store,
+				Runtime:  runtime,
+				Client:   client,
+				Element:  element,
+				Resource: resource,
+				Children: children,
 			}
 			if err := j.Do(ctx); err != nil {
-				return err
+				errs = multierror.Append(errs, errors.Wrap(err, element))
+
+				var unavailable job.StoreUnavailableError
+				if errors.As(err, &unavailable) || ctx.Err() != nil {
+					break
+				}
 			}
 		}
-		return nil
+		return errs
 	}
 }
+
+func cassettePath(dir string, store *config.Store) string {
+	return filepath.Join(dir, store.StoreID+".cassette.jsonl")
+}
@@ -30,7 +30,22 @@ func rewind(s io.Seeker) error {
 	return err
 }
 
+// Reorder sorts the records in rw by updated_at and id. Identical versions of
+// a record are written once.
 func Reorder(rw io.ReadWriteSeeker) error {
+	return ReorderTimestamps(rw, DefaultTimestamps, false)
+}
+
+// ReorderLatest sorts the records in rw like Reorder, but keeps only the
+// latest version of each id. Records without an id are always kept, though
+// identical copies of one are written once as in Reorder.
+func ReorderLatest(rw io.ReadWriteSeeker) error {
+	return ReorderTimestamps(rw, DefaultTimestamps, true)
+}
+
+// ReorderTimestamps is Reorder, or ReorderLatest if latest is set, for records
+// whose timestamps are read from ts, such as records that are never updated.
+func ReorderTimestamps(rw io.ReadWriteSeeker, ts Timestamps, latest bool) error {
 	if err := rewind(rw); err != nil {
 		return err
 	}
@@ -40,17 +55,31 @@ func Reorder(rw io.ReadWriteSeeker) error {
 		return err
 	}
 
-	r := NewReader(rw)
+	r := NewTimestampReader(rw, ts)
 	s := new(Set).Init()
 
+	versions := make(map[int64]*Item)
 	for r.Scan() {
-		s.Add(r.Item().Clone())
+		item := r.Item()
+		if !latest || item.ID == 0 {
+			s.Add(item.Clone())
+			continue
+		}
+
+		// on ties, the version later in the file wins
+		if prev, ok := versions[item.ID]; !ok || !prev.UpdatedAt.After(item.UpdatedAt) {
+			versions[item.ID] = item.Clone()
+		}
 	}
 
 	if err := r.Err(); err != nil {
 		return err
 	}
 
+	for _, item := range versions {
+		s.Add(item)
+	}
+
 	if err := rewind(rw); err != nil {
 		return err
 	}
@@ -0,0 +1,103 @@
+package data
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// reorder writes lines to a temporary file, reorders it and returns the
+// resulting lines.
+func reorder(t *testing.T, lines []string, reorder func(*os.File) error) []string {
+	t.Helper()
+
+	fp, err := ioutil.TempFile("", "reorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = fp.Close()
+		_ = os.Remove(fp.Name())
+	}()
+
+	if _, err := fp.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reorder(fp); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadFile(fp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+}
+
+func checkLines(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(got), len(want), strings.Join(got, "\n"))
+	}
+	for idx := range want {
+		if got[idx] != want[idx] {
+			t.Errorf("line %d = %s, want %s", idx, got[idx], want[idx])
+		}
+	}
+}
+
+func TestReorder(t *testing.T) {
+	lines := []string{
+		`{"id":2,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:02Z"}`,
+		`{"id":1,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:03Z","v":"b"}`,
+		`{"id":1,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:01Z"}`,
+		`{"id":2,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:02Z"}`,
+		`{"id":1,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:03Z","v":"a"}`,
+	}
+
+	got := reorder(t, lines, func(fp *os.File) error { return Reorder(fp) })
+
+	// identical copies are written once, but distinct records updated in
+	// the same second are all kept
+	checkLines(t, got, []string{
+		`{"id":1,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:01Z"}`,
+		`{"id":2,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:02Z"}`,
+		`{"id":1,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:03Z","v":"a"}`,
+		`{"id":1,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:03Z","v":"b"}`,
+	})
+}
+
+func TestReorderLatest(t *testing.T) {
+	lines := []string{
+		`{"id":1,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:05Z","v":"latest"}`,
+		`{"id":2,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:02Z","v":"first"}`,
+		`{"id":1,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:01Z"}`,
+		`{"id":2,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:02Z","v":"second"}`,
+		`{"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:03Z"}`,
+		`{"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:03Z"}`,
+	}
+
+	got := reorder(t, lines, func(fp *os.File) error { return ReorderLatest(fp) })
+
+	// on ties, the version later in the file wins
+	checkLines(t, got, []string{
+		`{"id":2,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:02Z","v":"second"}`,
+		`{"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:03Z"}`,
+		`{"id":1,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:05Z","v":"latest"}`,
+	})
+}
+
+func TestReorderTimestamps(t *testing.T) {
+	lines := []string{
+		`{"id":3,"created_at":"2020-01-01T00:00:01Z"}`,
+		`{"id":1,"created_at":"2020-01-01T00:00:03Z"}`,
+		`{"id":2,"created_at":"2020-01-01T00:00:02Z"}`,
+	}
+
+	// records that are never updated are ordered by created_at
+	ts := Timestamps{CreatedAt: "created_at"}
+	got := reorder(t, lines, func(fp *os.File) error { return ReorderTimestamps(fp, ts, false) })
+
+	checkLines(t, got, []string{lines[0], lines[2], lines[1]})
+}
@@ -1,28 +1,87 @@
 package data
 
 import (
+	"bytes"
+	"encoding/json"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 )
 
+const (
+	// DeletedField marks a tombstone: a record that was deleted in Shopify.
+	DeletedField = "_deleted"
+
+	// WebhookField holds the topic of a record that was delivered by a
+	// webhook rather than fetched from the API.
+	WebhookField = "_webhook"
+)
+
 type Item struct {
+	ID        int64
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	Deleted   bool
+	Webhook   bool
 	Raw       []byte
 }
 
+// NewTombstone returns a record marking id as deleted at the given time.
+// Both of its timestamps are the deletion time, so it sorts after every
+// version of the record it replaces.
+func NewTombstone(id int64, deletedAt time.Time) *Item {
+	deletedAt = deletedAt.UTC().Truncate(time.Second)
+	tombstone := struct {
+		ID        int64     `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Deleted   bool      `json:"_deleted"`
+	}{id, deletedAt, deletedAt, true}
+
+	// marshaling a struct of plain values cannot fail
+	raw, _ := json.Marshal(tombstone)
+	return &Item{
+		ID:        id,
+		CreatedAt: deletedAt,
+		UpdatedAt: deletedAt,
+		Deleted:   true,
+		Raw:       raw,
+	}
+}
+
 func (item *Item) Clone() *Item {
 	clone := new(Item)
+	clone.ID = item.ID
 	clone.CreatedAt = item.CreatedAt
 	clone.UpdatedAt = item.UpdatedAt
+	clone.Deleted = item.Deleted
+	clone.Webhook = item.Webhook
 	clone.Raw = make([]byte, len(item.Raw))
 	copy(clone.Raw, item.Raw)
 	return clone
 }
 
+// Timestamps names the JSON paths an Item reads its timestamps from. The zero
+// value reads no timestamps.
+type Timestamps struct {
+	CreatedAt string
+	// UpdatedAt may be empty for records that are never updated, in which
+	// case UpdatedAt is the same as CreatedAt.
+	UpdatedAt string
+}
+
+var DefaultTimestamps = Timestamps{
+	CreatedAt: "created_at",
+	UpdatedAt: "updated_at",
+}
+
 func (item *Item) UnmarshalJSON(data []byte) error {
+	return item.Decode(data, DefaultTimestamps)
+}
+
+// Decode is like UnmarshalJSON but reads the timestamps from the given paths.
+func (item *Item) Decode(data []byte, ts Timestamps) error {
 	if !gjson.ValidBytes(data) {
 		return errors.New("invalid JSON")
 	}
@@ -30,13 +89,63 @@ func (item *Item) UnmarshalJSON(data []byte) error {
 	item.Raw = make([]byte, len(data))
 	copy(item.Raw, data)
 
+	// records without an id (or with a non-numeric one) get 0
+	item.ID = gjson.GetBytes(data, "id").Int()
+	item.Deleted = gjson.GetBytes(data, DeletedField).Bool()
+	item.Webhook = gjson.GetBytes(data, WebhookField).Exists()
+
+	if ts.CreatedAt == "" {
+		return nil
+	}
+
 	var err error
-	if item.CreatedAt, err = getTime(data, "created_at"); err != nil {
+	if item.CreatedAt, err = getTime(data, ts.CreatedAt); err != nil {
 		return err
 	}
-	if item.UpdatedAt, err = getTime(data, "updated_at"); err != nil {
+	if ts.UpdatedAt == "" {
+		item.UpdatedAt = item.CreatedAt
+		return nil
+	}
+	if item.UpdatedAt, err = getTime(data, ts.UpdatedAt); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Annotate adds a top-level field to the item's JSON object. The key must be
+// a plain field name that the object does not already have.
+func (item *Item) Annotate(key string, value interface{}) error {
+	raw := bytes.TrimSpace(item.Raw)
+	if len(raw) < 2 || raw[0] != '{' {
+		return errors.New("item is not a JSON object")
+	}
+
+	if gjson.GetBytes(raw, key).Exists() {
+		return errors.Errorf("item already has a value for key: %s", key)
+	}
+
+	k, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	v, err := json.Marshal(value)
+	if err != nil {
 		return err
 	}
+
+	rest := bytes.TrimSpace(raw[1:])
+	buf := make([]byte, 0, len(raw)+len(k)+len(v)+2)
+	buf = append(buf, '{')
+	buf = append(buf, k...)
+	buf = append(buf, ':')
+	buf = append(buf, v...)
+	if rest[0] != '}' {
+		buf = append(buf, ',')
+	}
+	buf = append(buf, rest...)
+
+	item.Raw = buf
 	return nil
 }
 
@@ -0,0 +1,32 @@
+package data
+
+import (
+	"os"
+)
+
+// LockedWriter writes records to a file that other processes append to as
+// well, such as an element file shared by a sync and the webhook receiver.
+// Every record is written with a single write while holding an exclusive
+// advisory lock on the file, so records from different writers never
+// interleave. The file should be opened with os.O_APPEND.
+type LockedWriter struct {
+	fp *os.File
+	w  *Writer
+}
+
+func NewLockedWriter(fp *os.File) *LockedWriter {
+	return &LockedWriter{fp: fp, w: NewWriter(fp)}
+}
+
+func (w *LockedWriter) Write(item *Item) (err error) {
+	if err := lockFile(w.fp); err != nil {
+		return err
+	}
+	defer func() {
+		if uErr := unlockFile(w.fp); err == nil {
+			err = uErr
+		}
+	}()
+
+	return w.w.Write(item)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package data
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFile(fp *os.File) error {
+	for {
+		err := syscall.Flock(int(fp.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+func unlockFile(fp *os.File) error {
+	return syscall.Flock(int(fp.Fd()), syscall.LOCK_UN)
+}
@@ -6,13 +6,20 @@ import (
 )
 
 func NewReader(r io.Reader) *Reader {
-	return &Reader{decoder: json.NewDecoder(r)}
+	return NewTimestampReader(r, DefaultTimestamps)
+}
+
+// NewTimestampReader returns a reader that decodes items with the given
+// timestamp paths.
+func NewTimestampReader(r io.Reader, ts Timestamps) *Reader {
+	return &Reader{decoder: json.NewDecoder(r), timestamps: ts}
 }
 
 type Reader struct {
-	decoder *json.Decoder
-	error   error
-	item    *Item
+	decoder    *json.Decoder
+	timestamps Timestamps
+	error      error
+	item       *Item
 }
 
 func (r *Reader) Scan() bool {
@@ -22,7 +29,11 @@ func (r *Reader) Scan() bool {
 	if r.item == nil {
 		r.item = new(Item)
 	}
-	r.error = r.decoder.Decode(r.item)
+	var raw json.RawMessage
+	if r.error = r.decoder.Decode(&raw); r.error != nil {
+		return false
+	}
+	r.error = r.item.Decode(raw, r.timestamps)
 	return r.error == nil
 }
 
@@ -1,6 +1,8 @@
 package data
 
 import (
+	"bytes"
+
 	"github.com/emirpasic/gods/sets/treeset"
 )
 
@@ -9,7 +11,7 @@ type Set struct {
 }
 
 func (set *Set) Init() *Set {
-	set.set = treeset.NewWith(UpdatedAtComparator)
+	set.set = treeset.NewWith(UpdatedAtIDComparator)
 	return set
 }
 
@@ -38,3 +40,33 @@ func UpdatedAtComparator(a, b interface{}) int {
 
 	return timeComparator(v1.UpdatedAt, v2.UpdatedAt)
 }
+
+// UpdatedAtIDComparator orders items by UpdatedAt, then by ID and finally by
+// their JSON, so only identical records are considered equal. Distinct
+// records updated in the same second, including records without an id, are
+// all kept by a Set.
+func UpdatedAtIDComparator(a, b interface{}) int {
+	if c := UpdatedAtComparator(a, b); c != 0 {
+		return c
+	}
+
+	if c := IDComparator(a, b); c != 0 {
+		return c
+	}
+
+	return bytes.Compare(a.(*Item).Raw, b.(*Item).Raw)
+}
+
+func IDComparator(a, b interface{}) int {
+	v1 := a.(*Item)
+	v2 := b.(*Item)
+
+	switch {
+	case v1.ID < v2.ID:
+		return -1
+	case v1.ID > v2.ID:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,167 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+type Option func(p *Pool)
+
+// WithConcurrency limits the number of tasks running at once. Zero or less
+// means no limit.
+func WithConcurrency(concurrency int) Option {
+	return func(p *Pool) {
+		p.concurrency = concurrency
+	}
+}
+
+// WithFailFast cancels the pool's context when the first task fails, so
+// running tasks can stop early and queued tasks are not started.
+func WithFailFast() Option {
+	return func(p *Pool) {
+		p.failFast = true
+	}
+}
+
+// Result is the outcome of a task.
+type Result struct {
+	Name string
+	Err  error
+
+	// Queued is how long the task waited for a free slot.
+	Queued time.Duration
+
+	// Started is zero if the task never ran because the context was done.
+	Started  time.Time
+	Duration time.Duration
+}
+
+// PanicError is the error of a task that panicked.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.Value, e.Stack)
+}
+
+// Pool runs tasks concurrently, at most concurrency at a time, and collects
+// their results.
+type Pool struct {
+	context     context.Context
+	cancel      context.CancelFunc
+	concurrency int
+	failFast    bool
+
+	wg      sync.WaitGroup
+	slots   chan struct{}
+	mu      sync.Mutex
+	results []*Result
+}
+
+func New(ctx context.Context, options ...Option) *Pool {
+	p := &Pool{}
+	for _, opt := range options {
+		opt(p)
+	}
+
+	p.context, p.cancel = context.WithCancel(ctx)
+	if p.concurrency > 0 {
+		p.slots = make(chan struct{}, p.concurrency)
+	}
+	return p
+}
+
+// Go submits a task. It does not block; the task waits for a free slot in
+// its own goroutine. A panic in fn is recovered and becomes its PanicError.
+func (p *Pool) Go(name string, fn func(ctx context.Context) error) {
+	r := &Result{Name: name}
+
+	p.mu.Lock()
+	p.results = append(p.results, r)
+	p.mu.Unlock()
+
+	submitted := time.Now()
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		if err := p.acquire(); err != nil {
+			r.Queued = time.Since(submitted)
+			r.Err = err
+			return
+		}
+		defer p.release()
+
+		r.Started = time.Now()
+		r.Queued = r.Started.Sub(submitted)
+		r.Err = run(p.context, fn)
+		r.Duration = time.Since(r.Started)
+
+		if r.Err != nil && p.failFast {
+			p.cancel()
+		}
+	}()
+}
+
+// Wait waits for every submitted task and returns their results in the
+// order they were submitted, along with their errors combined.
+func (p *Pool) Wait() ([]Result, error) {
+	p.wg.Wait()
+	p.cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs error
+	results := make([]Result, len(p.results))
+	for idx, r := range p.results {
+		results[idx] = *r
+		if r.Err != nil {
+			errs = multierror.Append(errs, errors.Wrap(r.Err, r.Name))
+		}
+	}
+	return results, errs
+}
+
+func (p *Pool) acquire() error {
+	if p.slots == nil {
+		return p.context.Err()
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-p.context.Done():
+		return p.context.Err()
+	}
+
+	// a slot and cancellation may be ready at the same time
+	if err := p.context.Err(); err != nil {
+		p.release()
+		return err
+	}
+	return nil
+}
+
+func (p *Pool) release() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+func run(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+
+	return fn(ctx)
+}
@@ -0,0 +1,183 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestResultsInSubmissionOrder(t *testing.T) {
+	p := New(context.Background())
+	for idx := 0; idx < 10; idx++ {
+		idx := idx
+		p.Go(fmt.Sprint(idx), func(context.Context) error {
+			// later tasks finish first
+			time.Sleep(time.Duration(10-idx) * time.Millisecond)
+			if idx%3 == 0 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+	}
+
+	results, err := p.Wait()
+	if err == nil {
+		t.Fatal("Wait() returned no error")
+	}
+	if len(results) != 10 {
+		t.Fatalf("len(results) = %d, want 10", len(results))
+	}
+	for idx, r := range results {
+		if r.Name != fmt.Sprint(idx) {
+			t.Errorf("results[%d].Name = %q", idx, r.Name)
+		}
+		if failed := r.Err != nil; failed != (idx%3 == 0) {
+			t.Errorf("results[%d].Err = %v", idx, r.Err)
+		}
+		if r.Started.IsZero() {
+			t.Errorf("results[%d] never started", idx)
+		}
+	}
+	if !strings.Contains(err.Error(), "3: failed") {
+		t.Errorf("Wait() error %q does not name the failed task", err)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	const limit = 3
+
+	var running, peak int32
+	p := New(context.Background(), WithConcurrency(limit))
+	for idx := 0; idx < 12; idx++ {
+		p.Go(fmt.Sprint(idx), func(context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+	}
+
+	if _, err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if peak > limit {
+		t.Errorf("peak concurrency = %d, want at most %d", peak, limit)
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	p := New(context.Background(), WithConcurrency(1), WithFailFast())
+
+	// the first task holds the only slot until the others are queued
+	errFirst := errors.New("first")
+	started, queued := make(chan struct{}), make(chan struct{})
+	p.Go("first", func(context.Context) error {
+		close(started)
+		<-queued
+		return errFirst
+	})
+	<-started
+
+	var ran int32
+	for idx := 0; idx < 5; idx++ {
+		p.Go(fmt.Sprint(idx), func(context.Context) error {
+			atomic.AddInt32(&ran, 1)
+			return nil
+		})
+	}
+	close(queued)
+
+	results, err := p.Wait()
+	if !errors.Is(err, errFirst) {
+		t.Errorf("errors.Is(%v, errFirst) = false", err)
+	}
+	if ran != 0 {
+		t.Errorf("%d queued tasks ran after the failure", ran)
+	}
+	for _, r := range results[1:] {
+		if !errors.Is(r.Err, context.Canceled) || !r.Started.IsZero() {
+			t.Errorf("result %s = %v, started %s; want cancelled before starting", r.Name, r.Err, r.Started)
+		}
+	}
+}
+
+func TestFailFastCancelsRunningTasks(t *testing.T) {
+	p := New(context.Background(), WithFailFast())
+
+	started := make(chan struct{})
+	p.Go("slow", func(ctx context.Context) error {
+		close(started)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+	p.Go("failing", func(context.Context) error {
+		<-started
+		return errors.New("failed")
+	})
+
+	results, _ := p.Wait()
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("slow task error = %v, want context.Canceled", results[0].Err)
+	}
+}
+
+func TestPanicBecomesPanicError(t *testing.T) {
+	p := New(context.Background())
+	p.Go("panics", func(context.Context) error {
+		panic("boom")
+	})
+	p.Go("fine", func(context.Context) error {
+		return nil
+	})
+
+	results, err := p.Wait()
+	if err == nil {
+		t.Fatal("Wait() returned no error")
+	}
+
+	var panicErr PanicError
+	if !errors.As(results[0].Err, &panicErr) {
+		t.Fatalf("results[0].Err = %v, want a PanicError", results[0].Err)
+	}
+	if panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Errorf("PanicError = %v, %q", panicErr.Value, panicErr.Stack)
+	}
+	if results[1].Err != nil {
+		t.Errorf("results[1].Err = %v", results[1].Err)
+	}
+}
+
+func TestCancelledContextSkipsTasks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := New(ctx, WithConcurrency(2))
+	var ran int32
+	p.Go("task", func(context.Context) error {
+		atomic.AddInt32(&ran, 1)
+		return nil
+	})
+
+	results, _ := p.Wait()
+	if ran != 0 {
+		t.Error("task ran with a cancelled context")
+	}
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("results[0].Err = %v, want context.Canceled", results[0].Err)
+	}
+}
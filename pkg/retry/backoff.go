@@ -0,0 +1,174 @@
+package retry
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxDelay caps the delays of backoffs parsed without a maximum.
+const DefaultMaxDelay = 30 * time.Second
+
+// Backoff computes the wait before retrying. attempt is the number of the
+// attempt that just failed, starting at 1, and prev is the previous wait, or
+// zero after the first attempt.
+type Backoff interface {
+	Delay(attempt int, prev time.Duration) time.Duration
+}
+
+// BackoffFunc adapts a function to a Backoff.
+type BackoffFunc func(attempt int, prev time.Duration) time.Duration
+
+func (f BackoffFunc) Delay(attempt int, prev time.Duration) time.Duration {
+	return f(attempt, prev)
+}
+
+// Rand is the source of randomness of the jittered backoffs. *rand.Rand
+// satisfies it, so a seeded source makes them deterministic.
+type Rand interface {
+	Int63n(n int64) int64
+}
+
+type globalRand struct{}
+
+func (globalRand) Int63n(n int64) int64 {
+	return rand.Int63n(n)
+}
+
+// Constant waits d between every attempt.
+func Constant(d time.Duration) Backoff {
+	return BackoffFunc(func(int, time.Duration) time.Duration {
+		return d
+	})
+}
+
+// Exponential doubles the wait after every attempt, starting at base, up to
+// max.
+func Exponential(base, max time.Duration) Backoff {
+	return BackoffFunc(func(attempt int, _ time.Duration) time.Duration {
+		return exponential(base, max, attempt)
+	})
+}
+
+// FullJitter waits a random duration between zero and the Exponential wait.
+// A nil r uses the math/rand global source.
+func FullJitter(base, max time.Duration, r Rand) Backoff {
+	if r == nil {
+		r = globalRand{}
+	}
+
+	return BackoffFunc(func(attempt int, _ time.Duration) time.Duration {
+		d := exponential(base, max, attempt)
+		if d <= 0 {
+			return 0
+		}
+		return time.Duration(r.Int63n(int64(d) + 1))
+	})
+}
+
+// DecorrelatedJitter waits a random duration between base and three times the
+// previous wait, up to max. A nil r uses the math/rand global source.
+func DecorrelatedJitter(base, max time.Duration, r Rand) Backoff {
+	if r == nil {
+		r = globalRand{}
+	}
+
+	return BackoffFunc(func(_ int, prev time.Duration) time.Duration {
+		if prev < base {
+			prev = base
+		}
+
+		upper := prev * 3
+		if upper > max || upper < prev {
+			upper = max
+		}
+		if upper <= base {
+			return upper
+		}
+
+		return base + time.Duration(r.Int63n(int64(upper-base)+1))
+	})
+}
+
+// Fibonacci grows the wait along the Fibonacci sequence, base, 2*base,
+// 3*base, 5*base and so on, up to max. It grows more gently than Exponential.
+func Fibonacci(base, max time.Duration) Backoff {
+	return BackoffFunc(func(attempt int, _ time.Duration) time.Duration {
+		a, b := base, base
+		for idx := 1; idx < attempt; idx++ {
+			a, b = b, a+b
+			if b > max || b < a {
+				return max
+			}
+		}
+		if b > max {
+			return max
+		}
+		return b
+	})
+}
+
+func exponential(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for idx := 1; idx < attempt; idx++ {
+		prev := d
+		d *= 2
+		// a zero base stays zero, anything else overflows to less
+		if d > max || d < prev {
+			return max
+		}
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// ParseBackoff parses a backoff from `name:base[:max]`, where name is one of
+// constant, exponential, full-jitter, decorrelated-jitter or fibonacci. Only
+// constant accepts a zero base. The maximum defaults to DefaultMaxDelay and is
+// ignored by constant. A nil r uses the math/rand global source.
+func ParseBackoff(s string, r Rand) (Backoff, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, errors.Errorf("invalid backoff %q: expected name:base[:max]", s)
+	}
+
+	base, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid backoff %q", s)
+	}
+
+	max := DefaultMaxDelay
+	if len(parts) == 3 {
+		if max, err = time.ParseDuration(parts[2]); err != nil {
+			return nil, errors.Wrapf(err, "invalid backoff %q", s)
+		}
+	}
+
+	if base < 0 || max < base {
+		return nil, errors.Errorf("invalid backoff %q: expected 0 <= base <= max", s)
+	}
+
+	// the other strategies grow from base, which they never would from zero
+	if base == 0 && parts[0] != "constant" {
+		return nil, errors.Errorf("invalid backoff %q: %s needs a positive base", s, parts[0])
+	}
+
+	switch parts[0] {
+	case "constant":
+		return Constant(base), nil
+	case "exponential":
+		return Exponential(base, max), nil
+	case "full-jitter":
+		return FullJitter(base, max, r), nil
+	case "decorrelated-jitter":
+		return DecorrelatedJitter(base, max, r), nil
+	case "fibonacci":
+		return Fibonacci(base, max), nil
+	default:
+		return nil, errors.Errorf("unknown backoff %q", parts[0])
+	}
+}
@@ -0,0 +1,161 @@
+package retry
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestExponential(t *testing.T) {
+	b := Exponential(time.Second, time.Minute)
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{8, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := b.Delay(tt.attempt, 0); got != tt.want {
+			t.Errorf("Delay(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestExponentialOverflow(t *testing.T) {
+	max := time.Duration(math.MaxInt64)
+	b := Exponential(time.Second, max)
+	if got, want := b.Delay(34, 0), time.Second<<33; got != want {
+		t.Errorf("Delay(34) = %s, want %s", got, want)
+	}
+	for _, attempt := range []int{35, 64, 100, 1000} {
+		if got := b.Delay(attempt, 0); got != max {
+			t.Errorf("Delay(%d) = %s, want %s", attempt, got, max)
+		}
+	}
+}
+
+func TestFibonacci(t *testing.T) {
+	b := Fibonacci(time.Second, 10*time.Second)
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 5 * time.Second, 8 * time.Second, 10 * time.Second}
+	for idx, w := range want {
+		if got := b.Delay(idx+1, 0); got != w {
+			t.Errorf("Delay(%d) = %s, want %s", idx+1, got, w)
+		}
+	}
+}
+
+func TestJitterIsDeterministicWithSeededRand(t *testing.T) {
+	for name, newBackoff := range map[string]func(r Rand) Backoff{
+		"full-jitter": func(r Rand) Backoff {
+			return FullJitter(time.Second, time.Minute, r)
+		},
+		"decorrelated-jitter": func(r Rand) Backoff {
+			return DecorrelatedJitter(time.Second, time.Minute, r)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := newBackoff(rand.New(rand.NewSource(1)))
+			b := newBackoff(rand.New(rand.NewSource(1)))
+
+			var prevA, prevB time.Duration
+			for attempt := 1; attempt <= 10; attempt++ {
+				prevA, prevB = a.Delay(attempt, prevA), b.Delay(attempt, prevB)
+				if prevA != prevB {
+					t.Fatalf("attempt %d: %s != %s", attempt, prevA, prevB)
+				}
+				if prevA < 0 || prevA > time.Minute {
+					t.Fatalf("attempt %d: %s out of range", attempt, prevA)
+				}
+			}
+		})
+	}
+}
+
+func TestDecorrelatedJitterBounds(t *testing.T) {
+	b := DecorrelatedJitter(time.Second, 10*time.Second, rand.New(rand.NewSource(1)))
+	var prev time.Duration
+	for attempt := 1; attempt <= 100; attempt++ {
+		d := b.Delay(attempt, prev)
+		upper := 3 * prev
+		if upper < time.Second*3 {
+			upper = time.Second * 3
+		}
+		if upper > 10*time.Second {
+			upper = 10 * time.Second
+		}
+		if d < time.Second || d > upper {
+			t.Fatalf("attempt %d: %s not in [1s, %s]", attempt, d, upper)
+		}
+		prev = d
+	}
+}
+
+func TestParseBackoff(t *testing.T) {
+	tests := []struct {
+		s       string
+		attempt int
+		want    time.Duration
+		wantErr bool
+	}{
+		{s: "constant:0", attempt: 3, want: 0},
+		{s: "constant:2s", attempt: 3, want: 2 * time.Second},
+		{s: "exponential:1s", attempt: 3, want: 4 * time.Second},
+		{s: "exponential:1s:3s", attempt: 3, want: 3 * time.Second},
+		{s: "fibonacci:1s:1m", attempt: 4, want: 5 * time.Second},
+		{s: "exponential:0", wantErr: true},
+		{s: "full-jitter:0:1s", wantErr: true},
+		{s: "decorrelated-jitter:0s", wantErr: true},
+		{s: "fibonacci:0", wantErr: true},
+		{s: "exponential:-1s", wantErr: true},
+		{s: "exponential:2s:1s", wantErr: true},
+		{s: "exponential", wantErr: true},
+		{s: "exponential:1s:2s:3s", wantErr: true},
+		{s: "exponential:soon", wantErr: true},
+		{s: "linear:1s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			b, err := ParseBackoff(tt.s, rand.New(rand.NewSource(1)))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseBackoff(%q) succeeded, want error", tt.s)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBackoff(%q): %v", tt.s, err)
+			}
+			if got := b.Delay(tt.attempt, 0); got != tt.want {
+				t.Errorf("Delay(%d) = %s, want %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffReceivesPreviousWait(t *testing.T) {
+	var prevs []time.Duration
+	b := BackoffFunc(func(attempt int, prev time.Duration) time.Duration {
+		prevs = append(prevs, prev)
+		return time.Duration(attempt) * time.Millisecond
+	})
+
+	_ = Go(func() error { return errFlaky }, WithBackoff(b), WithMaxAttempts(4))
+
+	want := []time.Duration{0, time.Millisecond, 2 * time.Millisecond}
+	if len(prevs) != len(want) {
+		t.Fatalf("prevs = %v, want %v", prevs, want)
+	}
+	for idx := range want {
+		if prevs[idx] != want[idx] {
+			t.Errorf("prevs = %v, want %v", prevs, want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package retry
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBreakerOpen matches every BreakerOpenError.
+var ErrBreakerOpen = errors.New("circuit breaker open")
+
+// BreakerState is the state of a Breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen fails every call until the cool-down has passed.
+	BreakerOpen
+
+	// BreakerHalfOpen lets a single probe through. Its outcome closes or
+	// reopens the breaker.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// BreakerOpenError is returned by Allow while the breaker is open, or while
+// it is half-open and its probe has not finished.
+type BreakerOpenError struct {
+	Until    time.Time
+	Failures int
+}
+
+func (e BreakerOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open until %s after %d consecutive failures", e.Until.Format(time.RFC3339), e.Failures)
+}
+
+func (e BreakerOpenError) Is(target error) bool {
+	return target == ErrBreakerOpen
+}
+
+type BreakerOption func(b *Breaker)
+
+// WithOnStateChange calls onStateChange after every transition of the
+// breaker.
+func WithOnStateChange(onStateChange func(from, to BreakerState)) BreakerOption {
+	return func(b *Breaker) {
+		b.onStateChange = onStateChange
+	}
+}
+
+// WithClock replaces time.Now, e.g. to step through the cool-down in tests.
+func WithClock(now func() time.Time) BreakerOption {
+	return func(b *Breaker) {
+		b.now = now
+	}
+}
+
+// Breaker is a circuit breaker. It opens after threshold consecutive
+// failures, fails fast for the cool-down, and then lets a probe through to
+// decide whether to close again. Callers ask Allow before every call and
+// report its outcome with Success or Failure. A Breaker is safe for
+// concurrent use.
+type Breaker struct {
+	mu            sync.Mutex
+	threshold     int
+	coolDown      time.Duration
+	state         BreakerState
+	failures      int
+	openedAt      time.Time
+	probedAt      time.Time
+	trips         int
+	onStateChange func(from, to BreakerState)
+	now           func() time.Time
+}
+
+func NewBreaker(threshold int, coolDown time.Duration, options ...BreakerOption) *Breaker {
+	b := &Breaker{
+		threshold: threshold,
+		coolDown:  coolDown,
+		now:       time.Now,
+	}
+
+	for _, opt := range options {
+		opt(b)
+	}
+
+	return b
+}
+
+// Allow returns a BreakerOpenError if the call should not be made. Once the
+// cool-down has passed, it half-opens the breaker and allows one probe. A
+// probe that is never reported is replaced after another cool-down.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	from := b.state
+	now := b.now()
+
+	var err error
+	switch b.state {
+	case BreakerOpen:
+		if until := b.openedAt.Add(b.coolDown); now.Before(until) {
+			err = BreakerOpenError{Until: until, Failures: b.failures}
+			break
+		}
+		b.state = BreakerHalfOpen
+		b.probedAt = now
+	case BreakerHalfOpen:
+		if until := b.probedAt.Add(b.coolDown); now.Before(until) {
+			err = BreakerOpenError{Until: until, Failures: b.failures}
+			break
+		}
+		b.probedAt = now
+	}
+
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return err
+}
+
+// Success reports a successful call, closing the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	from := b.state
+	b.failures = 0
+	b.state = BreakerClosed
+	b.mu.Unlock()
+
+	b.notify(from, BreakerClosed)
+}
+
+// Failure reports a failed call. The breaker opens when a probe fails or
+// the failures reach the threshold.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	from := b.state
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+		b.trips++
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Trips returns how many times the breaker has opened.
+func (b *Breaker) Trips() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.trips
+}
+
+func (b *Breaker) notify(from, to BreakerState) {
+	if from != to && b.onStateChange != nil {
+		b.onStateChange(from, to)
+	}
+}
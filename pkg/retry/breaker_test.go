@@ -0,0 +1,158 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// clock is a manual clock for WithClock.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestBreaker(threshold int, coolDown time.Duration) (*Breaker, *clock, *[]string) {
+	c := &clock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var transitions []string
+	b := NewBreaker(threshold, coolDown,
+		WithClock(c.Now),
+		WithOnStateChange(func(from, to BreakerState) {
+			transitions = append(transitions, from.String()+" -> "+to.String())
+		}),
+	)
+	return b, c, &transitions
+}
+
+func TestBreakerOpensAtThreshold(t *testing.T) {
+	b, c, _ := newTestBreaker(3, time.Minute)
+
+	for idx := 0; idx < 2; idx++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow() = %v before the threshold", err)
+		}
+		b.Failure()
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("State() = %s, want closed", b.State())
+	}
+
+	b.Failure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("State() = %s, want open", b.State())
+	}
+
+	err := b.Allow()
+	var openErr BreakerOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("Allow() = %v, want a BreakerOpenError", err)
+	}
+	if !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("errors.Is(%v, ErrBreakerOpen) = false", err)
+	}
+	if want := c.Now().Add(time.Minute); !openErr.Until.Equal(want) {
+		t.Errorf("Until = %s, want %s", openErr.Until, want)
+	}
+	if openErr.Failures != 3 {
+		t.Errorf("Failures = %d, want 3", openErr.Failures)
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b, _, _ := newTestBreaker(2, time.Minute)
+
+	b.Failure()
+	b.Success()
+	b.Failure()
+	if b.State() != BreakerClosed {
+		t.Errorf("State() = %s, want closed", b.State())
+	}
+}
+
+func TestBreakerProbe(t *testing.T) {
+	b, c, transitions := newTestBreaker(1, time.Minute)
+
+	b.Failure()
+	c.Advance(30 * time.Second)
+	if err := b.Allow(); err == nil {
+		t.Fatal("Allow() succeeded during the cool-down")
+	}
+
+	c.Advance(30 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() = %v after the cool-down", err)
+	}
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("State() = %s, want half-open", b.State())
+	}
+
+	// only one probe at a time
+	if err := b.Allow(); err == nil {
+		t.Fatal("Allow() let a second probe through")
+	}
+
+	// the probe fails and the breaker reopens for another cool-down
+	b.Failure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("State() = %s, want open", b.State())
+	}
+	if err := b.Allow(); err == nil {
+		t.Fatal("Allow() succeeded after the probe failed")
+	}
+
+	c.Advance(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() = %v after the second cool-down", err)
+	}
+	b.Success()
+	if b.State() != BreakerClosed {
+		t.Fatalf("State() = %s, want closed", b.State())
+	}
+	if b.Trips() != 2 {
+		t.Errorf("Trips() = %d, want 2", b.Trips())
+	}
+
+	want := []string{
+		"closed -> open",
+		"open -> half-open",
+		"half-open -> open",
+		"open -> half-open",
+		"half-open -> closed",
+	}
+	if len(*transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", *transitions, want)
+	}
+	for idx := range want {
+		if (*transitions)[idx] != want[idx] {
+			t.Errorf("transitions = %v, want %v", *transitions, want)
+			break
+		}
+	}
+}
+
+func TestBreakerReplacesLostProbe(t *testing.T) {
+	b, c, _ := newTestBreaker(1, time.Minute)
+
+	b.Failure()
+	c.Advance(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() = %v after the cool-down", err)
+	}
+
+	// the probe is never reported
+	c.Advance(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() = %v, want a new probe", err)
+	}
+	if b.State() != BreakerHalfOpen {
+		t.Errorf("State() = %s, want half-open", b.State())
+	}
+}
@@ -0,0 +1,72 @@
+package retry
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PermanentError marks an error that must not be retried.
+type PermanentError struct {
+	Err error
+}
+
+// Permanent marks err so that Do returns it without retrying. It returns nil
+// if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return PermanentError{Err: err}
+}
+
+func (e PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfterError marks an error that may be retried once After has passed.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+// RetryAfter marks err so that Do waits at least d before the next attempt.
+// It returns nil if err is nil.
+func RetryAfter(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return RetryAfterError{Err: err, After: d}
+}
+
+func (e RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err may be retried, i.e. it is not nil and
+// not marked Permanent.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var permanent PermanentError
+	return !errors.As(err, &permanent)
+}
+
+// RetryAfterOf returns the delay requested by a RetryAfter marker in err.
+func RetryAfterOf(err error) (time.Duration, bool) {
+	var retryAfter RetryAfterError
+	if errors.As(err, &retryAfter) {
+		return retryAfter.After, true
+	}
+	return 0, false
+}
@@ -0,0 +1,98 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+func TestMarkersOfNil(t *testing.T) {
+	if err := Permanent(nil); err != nil {
+		t.Errorf("Permanent(nil) = %v", err)
+	}
+	if err := RetryAfter(nil, time.Second); err != nil {
+		t.Errorf("RetryAfter(nil) = %v", err)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "plain", err: errFlaky, want: true},
+		{name: "permanent", err: Permanent(errFlaky), want: false},
+		{name: "wrapped permanent", err: errors.Wrap(Permanent(errFlaky), "context"), want: false},
+		{name: "retry after", err: RetryAfter(errFlaky, time.Second), want: true},
+		{name: "permanent retry after", err: Permanent(RetryAfter(errFlaky, time.Second)), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfterOf(t *testing.T) {
+	err := errors.Wrap(RetryAfter(errFlaky, 3*time.Second), "context")
+	if d, ok := RetryAfterOf(err); !ok || d != 3*time.Second {
+		t.Errorf("RetryAfterOf(%v) = %s, %t, want 3s, true", err, d, ok)
+	}
+	if !errors.Is(err, errFlaky) {
+		t.Errorf("errors.Is(%v, errFlaky) = false", err)
+	}
+
+	if _, ok := RetryAfterOf(errFlaky); ok {
+		t.Errorf("RetryAfterOf(%v) found a marker", errFlaky)
+	}
+}
+
+func TestGoErrorKeepsEveryAttempt(t *testing.T) {
+	open := BreakerOpenError{Until: time.Now(), Failures: 3}
+	var calls int
+	err := Go(func() error {
+		if calls++; calls == 1 {
+			return open
+		}
+		return errFlaky
+	}, WithDelay(0), WithMaxAttempts(2))
+
+	if !errors.Is(err, errFlaky) {
+		t.Error("errors.Is(err, errFlaky) = false")
+	}
+	if !errors.Is(err, ErrBreakerOpen) {
+		t.Error("errors.Is(err, ErrBreakerOpen) = false")
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Error("errors.Is(err, context.Canceled) = true")
+	}
+
+	var openErr BreakerOpenError
+	if !errors.As(err, &openErr) || openErr.Failures != 3 {
+		t.Errorf("errors.As(err, &openErr) = %+v", openErr)
+	}
+}
+
+func TestGoErrorEndsWithTheReasonToGiveUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	err := GoContext(ctx, func(context.Context) error {
+		cancel()
+		return errFlaky
+	}, WithDelay(time.Hour))
+
+	var multi *multierror.Error
+	if !errors.As(err, &multi) || len(multi.Errors) != 2 {
+		t.Fatalf("err = %v, want the attempt's error and the context's", err)
+	}
+	if multi.Errors[0] != errFlaky || multi.Errors[1] != context.Canceled {
+		t.Errorf("Errors = %v, want [flaky, context canceled]", multi.Errors)
+	}
+}
@@ -1,15 +1,23 @@
 package retry
 
 import (
+	"context"
 	"time"
 )
 
 type Option func(retryable *funcRetryable)
 
 func New(do func() error, options ...Option) Retryable {
+	return NewContext(func(context.Context) error { return do() }, options...)
+}
+
+// NewContext returns a Retryable whose attempts are passed the context given
+// to DoContext.
+func NewContext(do func(context.Context) error, options ...Option) Retryable {
 	r := funcRetryable{
 		do:          do,
-		doRetry:     func(attempts int, err error) (time.Duration, bool) { return DefaultDelay, true },
+		doRetry:     adaptDoRetry(func(error) bool { return true }),
+		backoff:     Constant(DefaultDelay),
 		maxAttempts: DefaultMaxAttempts,
 	}
 
@@ -26,12 +34,20 @@ func WithMaxAttempts(maxAttempts int) Option {
 	}
 }
 
+// WithDoRetry decides whether a retryable error is retried. Errors marked
+// Permanent are never retried, and the wait is up to the backoff and any
+// RetryAfter marker.
 func WithDoRetry(doRetry func(error) bool) Option {
 	return func(retryable *funcRetryable) {
 		retryable.doRetry = adaptDoRetry(doRetry)
 	}
 }
 
+// WithDoRetryWithDelay decides whether an error is retried and the least time
+// to wait before retrying it.
+//
+// Deprecated: use WithDoRetry, and mark errors RetryAfter to wait longer
+// than the backoff.
 func WithDoRetryWithDelay(doRetry func(int, error) (time.Duration, bool)) Option {
 	return func(retryable *funcRetryable) {
 		retryable.doRetry = doRetry
@@ -40,9 +56,32 @@ func WithDoRetryWithDelay(doRetry func(int, error) (time.Duration, bool)) Option
 
 func WithDelay(delay time.Duration) Option {
 	return func(retryable *funcRetryable) {
-		retryable.doRetry = func(attempt int, err error) (time.Duration, bool) {
-			return delay, true
-		}
+		retryable.backoff = Constant(delay)
+	}
+}
+
+// WithMaxElapsedTime limits the total time spent on attempts and waits.
+// Zero means no limit.
+func WithMaxElapsedTime(maxElapsedTime time.Duration) Option {
+	return func(retryable *funcRetryable) {
+		retryable.maxElapsedTime = maxElapsedTime
+	}
+}
+
+// WithAttemptTimeout sets a deadline on the context of each attempt. Zero
+// means no deadline. The context is cancelled when the attempt returns; see
+// Detach for attempts whose result outlives them.
+func WithAttemptTimeout(attemptTimeout time.Duration) Option {
+	return func(retryable *funcRetryable) {
+		retryable.attemptTimeout = attemptTimeout
+	}
+}
+
+// WithBackoff waits according to backoff between attempts, or longer if the
+// error is marked RetryAfter.
+func WithBackoff(backoff Backoff) Option {
+	return func(retryable *funcRetryable) {
+		retryable.backoff = backoff
 	}
 }
 
@@ -53,14 +92,21 @@ func WithOnRetry(onRetry func(int, time.Duration, error)) Option {
 }
 
 type funcRetryable struct {
-	do          func() error
-	doRetry     func(int, error) (time.Duration, bool)
-	onRetry     func(int, time.Duration, error)
-	maxAttempts int
+	do             func(context.Context) error
+	doRetry        func(int, error) (time.Duration, bool)
+	onRetry        func(int, time.Duration, error)
+	maxAttempts    int
+	maxElapsedTime time.Duration
+	attemptTimeout time.Duration
+	backoff        Backoff
 }
 
 func (r funcRetryable) Do() error {
-	return r.do()
+	return r.do(context.Background())
+}
+
+func (r funcRetryable) DoContext(ctx context.Context) error {
+	return r.do(ctx)
 }
 
 func (r funcRetryable) DoRetry(attempt int, err error) (time.Duration, bool) {
@@ -71,6 +117,18 @@ func (r funcRetryable) MaxAttempts() int {
 	return r.maxAttempts
 }
 
+func (r funcRetryable) MaxElapsedTime() time.Duration {
+	return r.maxElapsedTime
+}
+
+func (r funcRetryable) AttemptTimeout() time.Duration {
+	return r.attemptTimeout
+}
+
+func (r funcRetryable) Backoff() Backoff {
+	return r.backoff
+}
+
 func (r funcRetryable) OnRetry(attempt int, wait time.Duration, err error) {
 	f := r.onRetry
 	if f != nil {
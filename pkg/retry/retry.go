@@ -1,6 +1,7 @@
 package retry
 
 import (
+	"context"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -13,6 +14,10 @@ const (
 )
 
 var (
+	// DefaultDoRetry retries every error after DefaultDelay.
+	//
+	// Deprecated: errors are retried by default, waiting as long as the
+	// backoff says; mark them Permanent or RetryAfter instead.
 	DefaultDoRetry = func(attempt int, err error) (time.Duration, bool) { return DefaultDelay, true }
 )
 
@@ -30,6 +35,10 @@ type RetryableDoRetry interface {
 	DoRetry(err error) bool
 }
 
+// RetryableDoRetryWithDelay is a Retryable whose DoRetry also returns a floor
+// on the wait before the next attempt, like a RetryAfter marker.
+//
+// Deprecated: implement RetryableDoRetry and mark errors RetryAfter instead.
 type RetryableDoRetryWithDelay interface {
 	Retryable
 	DoRetry(attempt int, err error) (time.Duration, bool)
@@ -40,21 +49,73 @@ type RetryableOnRetry interface {
 	OnRetry(attempt int, wait time.Duration, err error)
 }
 
+// RetryableContext is a Retryable whose attempts observe a context. DoContext
+// is called instead of Do by DoContext.
+type RetryableContext interface {
+	Retryable
+	DoContext(ctx context.Context) error
+}
+
+// RetryableMaxElapsedTime is a Retryable with a budget for all of its
+// attempts and waits. No attempt is made once the budget would be exceeded.
+type RetryableMaxElapsedTime interface {
+	Retryable
+	MaxElapsedTime() time.Duration
+}
+
+// RetryableBackoff is a Retryable with a backoff. Other Retryables wait
+// DefaultDelay between attempts.
+type RetryableBackoff interface {
+	Retryable
+	Backoff() Backoff
+}
+
+// RetryableAttemptTimeout is a Retryable with a deadline for each attempt.
+// The deadline only applies to the context passed to DoContext, which is
+// cancelled when the attempt returns unless the attempt Detaches it.
+type RetryableAttemptTimeout interface {
+	Retryable
+	AttemptTimeout() time.Duration
+}
+
 func Go(do func() error, options ...Option) error {
 	r := New(do, options...)
 	return Do(r)
 }
 
+// GoContext is the context-aware Go: do is called with a context that is
+// done when ctx is, or when the attempt times out.
+func GoContext(ctx context.Context, do func(context.Context) error, options ...Option) error {
+	r := NewContext(do, options...)
+	return DoContext(ctx, r)
+}
+
 func Do(retryable Retryable) error {
+	return DoContext(context.Background(), retryable)
+}
+
+// DoContext retries like Do, but stops waiting as soon as ctx is done, in
+// which case the context's error is returned along with the attempts'.
+//
+// Errors marked Permanent are not retried, whatever the retryable's DoRetry
+// says, and errors marked RetryAfter wait at least as long as requested, even
+// if the backoff is shorter. When it gives up, DoContext returns a
+// *multierror.Error holding the error of every attempt, followed by the reason
+// for giving up if it was not the last attempt's error, such as the context
+// being done.
+func DoContext(ctx context.Context, retryable Retryable) error {
 	if retryable == nil {
 		return errors.New("retryable is nil")
 	}
 
 	var (
-		do          = retryable.Do
-		doRetry     = doRetry(retryable)
-		onRetry     = onRetry(retryable)
-		maxAttempts = maxAttempts(retryable)
+		do             = doContext(retryable)
+		doRetry        = doRetry(retryable)
+		onRetry        = onRetry(retryable)
+		maxAttempts    = maxAttempts(retryable)
+		maxElapsedTime = maxElapsedTime(retryable)
+		attemptTimeout = attemptTimeout(retryable)
+		backoff        = backoff(retryable)
 	)
 
 	if maxAttempts < 1 {
@@ -62,24 +123,121 @@ func Do(retryable Retryable) error {
 	}
 
 	var multi multierror.Error
+	giveUp := func(err error) error {
+		if err != nil {
+			multi.Errors = append(multi.Errors, err)
+		}
+		return &multi
+	}
+
+	start := time.Now()
 	attempts := 0
+	var prev time.Duration
 	for {
+		if err := ctx.Err(); err != nil {
+			return giveUp(err)
+		}
+
 		attempts++
-		err := do()
+		err := attempt(ctx, do, attemptTimeout)
 		if err == nil {
 			return nil
 		}
 
 		multi.Errors = append(multi.Errors, err)
-		wait, next := doRetry(attempts, err)
-		if !(next && attempts < maxAttempts) {
-			return multi.Unwrap()
+		if !IsRetryable(err) || attempts >= maxAttempts {
+			return giveUp(nil)
+		}
+		if err := ctx.Err(); err != nil {
+			return giveUp(err)
+		}
+
+		delay, next := doRetry(attempts, err)
+		if !next {
+			return giveUp(nil)
+		}
+
+		wait := backoff.Delay(attempts, prev)
+		if delay > wait {
+			wait = delay
+		}
+		if d, ok := RetryAfterOf(err); ok && d > wait {
+			wait = d
+		}
+		prev = wait
+
+		if elapsed := time.Since(start); maxElapsedTime > 0 && elapsed+wait > maxElapsedTime {
+			return giveUp(errors.Errorf("gave up after %d attempts in %s", attempts, elapsed.Round(time.Millisecond)))
 		}
 		onRetry(attempts, wait, err)
-		time.Sleep(wait)
+		if err := sleep(ctx, wait); err != nil {
+			return giveUp(err)
+		}
+	}
+}
+
+type detachKey struct{}
+
+// detachable is the cancellation of an attempt's context, which the attempt
+// may take over with Detach.
+type detachable struct {
+	cancel   context.CancelFunc
+	detached bool
+}
+
+// Detach takes over the cancellation of an attempt's context, which DoContext
+// otherwise cancels as soon as the attempt returns. An attempt whose result
+// still reads from the context, such as an HTTP response body, detaches it
+// and calls the returned function once it is done. The deadline still
+// applies. Outside of an attempt with a timeout, it returns a no-op.
+func Detach(ctx context.Context) context.CancelFunc {
+	if d, ok := ctx.Value(detachKey{}).(*detachable); ok && !d.detached {
+		d.detached = true
+		return d.cancel
+	}
+	return func() {}
+}
+
+func attempt(ctx context.Context, do func(context.Context) error, timeout time.Duration) error {
+	if timeout > 0 {
+		d := new(detachable)
+		ctx, d.cancel = context.WithTimeout(ctx, timeout)
+		ctx = context.WithValue(ctx, detachKey{}, d)
+		defer func() {
+			if !d.detached {
+				d.cancel()
+			}
+		}()
+	}
+
+	return do(ctx)
+}
+
+// sleep waits for d or until ctx is done, whichever is first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
+func doContext(retryable Retryable) func(context.Context) error {
+	if r, ok := retryable.(RetryableContext); ok {
+		return r.DoContext
+	}
+
+	return func(context.Context) error { return retryable.Do() }
+}
+
 func maxAttempts(retryable Retryable) int {
 	if r, ok := retryable.(RetryableAttempts); ok {
 		return r.MaxAttempts()
@@ -88,6 +246,30 @@ func maxAttempts(retryable Retryable) int {
 	return DefaultMaxAttempts
 }
 
+func maxElapsedTime(retryable Retryable) time.Duration {
+	if r, ok := retryable.(RetryableMaxElapsedTime); ok {
+		return r.MaxElapsedTime()
+	}
+
+	return 0
+}
+
+func backoff(retryable Retryable) Backoff {
+	if r, ok := retryable.(RetryableBackoff); ok && r.Backoff() != nil {
+		return r.Backoff()
+	}
+
+	return Constant(DefaultDelay)
+}
+
+func attemptTimeout(retryable Retryable) time.Duration {
+	if r, ok := retryable.(RetryableAttemptTimeout); ok {
+		return r.AttemptTimeout()
+	}
+
+	return 0
+}
+
 func doRetry(retryable Retryable) func(int, error) (time.Duration, bool) {
 	switch r := retryable.(type) {
 	case RetryableDoRetry:
@@ -95,7 +277,7 @@ func doRetry(retryable Retryable) func(int, error) (time.Duration, bool) {
 	case RetryableDoRetryWithDelay:
 		return r.DoRetry
 	default:
-		return DefaultDoRetry
+		return adaptDoRetry(func(error) bool { return true })
 	}
 }
 
@@ -109,6 +291,6 @@ func onRetry(retryable Retryable) func(int, time.Duration, error) {
 
 func adaptDoRetry(doRetry func(error) bool) func(int, error) (time.Duration, bool) {
 	return func(attempt int, err error) (time.Duration, bool) {
-		return DefaultDelay, doRetry(err)
+		return 0, doRetry(err)
 	}
 }
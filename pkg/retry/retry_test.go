@@ -0,0 +1,238 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+var errFlaky = errors.New("flaky")
+
+func TestGoSucceedsAfterRetries(t *testing.T) {
+	var calls int
+	err := Go(func() error {
+		if calls++; calls < 3 {
+			return errFlaky
+		}
+		return nil
+	}, WithDelay(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestGoGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls int
+	err := Go(func() error {
+		calls++
+		return errFlaky
+	}, WithDelay(0), WithMaxAttempts(4))
+
+	var multi *multierror.Error
+	if !errors.As(err, &multi) {
+		t.Fatalf("err = %v, want a *multierror.Error", err)
+	}
+	if calls != 4 || len(multi.Errors) != 4 {
+		t.Errorf("calls = %d, len(Errors) = %d, want 4", calls, len(multi.Errors))
+	}
+	if !errors.Is(err, errFlaky) {
+		t.Errorf("errors.Is(%v, errFlaky) = false", err)
+	}
+}
+
+func TestGoDoesNotRetryPermanent(t *testing.T) {
+	var calls int
+	err := Go(func() error {
+		calls++
+		return Permanent(errFlaky)
+	}, WithDelay(0), WithDoRetry(func(error) bool { return true }))
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if !errors.Is(err, errFlaky) {
+		t.Errorf("errors.Is(%v, errFlaky) = false", err)
+	}
+}
+
+func TestGoStopsWhenDoRetryDeclines(t *testing.T) {
+	var calls int
+	_ = Go(func() error {
+		calls++
+		return errFlaky
+	}, WithDelay(0), WithDoRetry(func(err error) bool { return false }))
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRetryAfterIsAFloor(t *testing.T) {
+	var waits []time.Duration
+	var calls int
+	err := Go(func() error {
+		if calls++; calls == 1 {
+			return RetryAfter(errFlaky, 20*time.Millisecond)
+		}
+		if calls == 2 {
+			return RetryAfter(errFlaky, time.Millisecond)
+		}
+		return nil
+	},
+		WithDelay(10*time.Millisecond),
+		WithOnRetry(func(_ int, wait time.Duration, _ error) {
+			waits = append(waits, wait)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []time.Duration{20 * time.Millisecond, 10 * time.Millisecond}
+	if len(waits) != len(want) {
+		t.Fatalf("waits = %v, want %v", waits, want)
+	}
+	for idx := range want {
+		if waits[idx] != want[idx] {
+			t.Errorf("waits = %v, want %v", waits, want)
+		}
+	}
+}
+
+func TestDoRetryWithDelayIsAFloor(t *testing.T) {
+	var waits []time.Duration
+	err := Go(func() error {
+		return errFlaky
+	},
+		WithDelay(time.Millisecond),
+		WithMaxAttempts(3),
+		WithDoRetryWithDelay(func(attempt int, err error) (time.Duration, bool) {
+			return time.Duration(attempt) * 5 * time.Millisecond, true
+		}),
+		WithOnRetry(func(_ int, wait time.Duration, _ error) {
+			waits = append(waits, wait)
+		}),
+	)
+	if !errors.Is(err, errFlaky) {
+		t.Errorf("errors.Is(%v, errFlaky) = false", err)
+	}
+
+	want := []time.Duration{5 * time.Millisecond, 10 * time.Millisecond}
+	if len(waits) != len(want) {
+		t.Fatalf("waits = %v, want %v", waits, want)
+	}
+	for idx := range want {
+		if waits[idx] != want[idx] {
+			t.Errorf("waits = %v, want %v", waits, want)
+		}
+	}
+}
+
+func TestMaxElapsedTime(t *testing.T) {
+	var calls int
+	err := Go(func() error {
+		calls++
+		return errFlaky
+	}, WithDelay(time.Hour), WithMaxElapsedTime(time.Minute))
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if !errors.Is(err, errFlaky) {
+		t.Errorf("errors.Is(%v, errFlaky) = false", err)
+	}
+}
+
+func TestDoContextStopsWaitingWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- GoContext(ctx, func(context.Context) error {
+			calls++
+			return errFlaky
+		}, WithDelay(time.Hour))
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("errors.Is(%v, context.Canceled) = false", err)
+		}
+		if !errors.Is(err, errFlaky) {
+			t.Errorf("errors.Is(%v, errFlaky) = false", err)
+		}
+		if calls != 1 {
+			t.Errorf("calls = %d, want 1", calls)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("GoContext did not return after cancel")
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	var calls int
+	err := GoContext(context.Background(), func(ctx context.Context) error {
+		if calls++; calls == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}, WithDelay(0), WithAttemptTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestDetach(t *testing.T) {
+	var attemptCtx context.Context
+	var cancel context.CancelFunc
+	err := GoContext(context.Background(), func(ctx context.Context) error {
+		attemptCtx = ctx
+		cancel = Detach(ctx)
+		return nil
+	}, WithAttemptTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := attemptCtx.Err(); err != nil {
+		t.Fatalf("detached context is done: %v", err)
+	}
+	cancel()
+	if err := attemptCtx.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("context error after cancel = %v, want context.Canceled", err)
+	}
+}
+
+func TestAttemptContextIsCancelledWithoutDetach(t *testing.T) {
+	var attemptCtx context.Context
+	err := GoContext(context.Background(), func(ctx context.Context) error {
+		attemptCtx = ctx
+		return nil
+	}, WithAttemptTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := attemptCtx.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestDetachOutsideAttempt(t *testing.T) {
+	ctx := context.Background()
+	Detach(ctx)()
+	if err := ctx.Err(); err != nil {
+		t.Errorf("ctx.Err() = %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+package shopify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultBulkPollInterval = 5 * time.Second
+
+// BulkOperationsVersion is the first API version with bulk operations.
+const BulkOperationsVersion = "2019-10"
+
+type BulkOperationStatus string
+
+const (
+	BulkOperationCreated   BulkOperationStatus = "CREATED"
+	BulkOperationRunning   BulkOperationStatus = "RUNNING"
+	BulkOperationCompleted BulkOperationStatus = "COMPLETED"
+	BulkOperationCanceling BulkOperationStatus = "CANCELING"
+	BulkOperationCanceled  BulkOperationStatus = "CANCELED"
+	BulkOperationFailed    BulkOperationStatus = "FAILED"
+	BulkOperationExpired   BulkOperationStatus = "EXPIRED"
+)
+
+const bulkOperationFields = `
+	id
+	status
+	errorCode
+	objectCount
+	fileSize
+	url
+	partialDataUrl
+	createdAt
+	completedAt
+`
+
+const bulkOperationRunQuery = `
+mutation bulkOperationRunQuery($query: String!) {
+	bulkOperationRunQuery(query: $query) {
+		bulkOperation {` + bulkOperationFields + `}
+		userErrors {
+			field
+			message
+		}
+	}
+}
+`
+
+const bulkOperationCancel = `
+mutation bulkOperationCancel($id: ID!) {
+	bulkOperationCancel(id: $id) {
+		bulkOperation {` + bulkOperationFields + `}
+		userErrors {
+			field
+			message
+		}
+	}
+}
+`
+
+const bulkOperationNode = `
+query bulkOperation($id: ID!) {
+	node(id: $id) {
+		... on BulkOperation {` + bulkOperationFields + `}
+	}
+}
+`
+
+// The bulk queries select a subset of the REST resource's fields, aliased to
+// their REST names so the result can be decoded as a data.Item. The shapes
+// still differ from REST records: ids are legacyResourceId strings and tags
+// are arrays. Bulk results are therefore written to their own output file
+// rather than mixed into the REST one.
+const (
+	ordersBulkQuery = `
+{
+	orders {
+		edges {
+			node {
+				id: legacyResourceId
+				admin_graphql_api_id: id
+				name
+				email
+				phone
+				note
+				tags
+				test
+				created_at: createdAt
+				updated_at: updatedAt
+				processed_at: processedAt
+				closed_at: closedAt
+				cancelled_at: cancelledAt
+				cancel_reason: cancelReason
+				currency: currencyCode
+				financial_status: displayFinancialStatus
+				fulfillment_status: displayFulfillmentStatus
+				subtotal_price_set: subtotalPriceSet { shop_money: shopMoney { amount currency_code: currencyCode } }
+				total_price_set: totalPriceSet { shop_money: shopMoney { amount currency_code: currencyCode } }
+				total_tax_set: totalTaxSet { shop_money: shopMoney { amount currency_code: currencyCode } }
+				customer { id: legacyResourceId admin_graphql_api_id: id }
+			}
+		}
+	}
+}
+`
+
+	productsBulkQuery = `
+{
+	products {
+		edges {
+			node {
+				id: legacyResourceId
+				admin_graphql_api_id: id
+				title
+				handle
+				body_html: descriptionHtml
+				vendor
+				product_type: productType
+				tags
+				created_at: createdAt
+				updated_at: updatedAt
+				published_at: publishedAt
+			}
+		}
+	}
+}
+`
+
+	customersBulkQuery = `
+{
+	customers {
+		edges {
+			node {
+				id: legacyResourceId
+				admin_graphql_api_id: id
+				email
+				phone
+				first_name: firstName
+				last_name: lastName
+				note
+				tags
+				state
+				verified_email: verifiedEmail
+				accepts_marketing: acceptsMarketing
+				orders_count: ordersCount
+				total_spent: totalSpent
+				created_at: createdAt
+				updated_at: updatedAt
+			}
+		}
+	}
+}
+`
+)
+
+type BulkOperation struct {
+	ID             string              `json:"id"`
+	Status         BulkOperationStatus `json:"status"`
+	ErrorCode      *string             `json:"errorCode"`
+	ObjectCount    string              `json:"objectCount"`
+	FileSize       *string             `json:"fileSize"`
+	URL            *string             `json:"url"`
+	PartialDataURL *string             `json:"partialDataUrl"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	CompletedAt    *time.Time          `json:"completedAt"`
+}
+
+// Done reports whether the operation has reached a terminal status.
+func (op *BulkOperation) Done() bool {
+	switch op.Status {
+	case BulkOperationCompleted, BulkOperationCanceled, BulkOperationFailed, BulkOperationExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+// RunBulkQuery starts a bulk operation for the given query. Only one bulk
+// operation may run per store at a time.
+func (c *Client) RunBulkQuery(ctx context.Context, query string) (*BulkOperation, error) {
+	var resp struct {
+		BulkOperationRunQuery struct {
+			BulkOperation *BulkOperation `json:"bulkOperation"`
+		} `json:"bulkOperationRunQuery"`
+	}
+
+	variables := map[string]interface{}{"query": query}
+	if err := c.Mutate(ctx, bulkOperationRunQuery, variables, &resp); err != nil {
+		return nil, err
+	}
+
+	op := resp.BulkOperationRunQuery.BulkOperation
+	if op == nil {
+		return nil, errors.New("bulk operation was not created")
+	}
+
+	return op, nil
+}
+
+func (c *Client) CancelBulkOperation(ctx context.Context, id string) (*BulkOperation, error) {
+	var resp struct {
+		BulkOperationCancel struct {
+			BulkOperation *BulkOperation `json:"bulkOperation"`
+		} `json:"bulkOperationCancel"`
+	}
+
+	variables := map[string]interface{}{"id": id}
+	if err := c.Mutate(ctx, bulkOperationCancel, variables, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.BulkOperationCancel.BulkOperation, nil
+}
+
+func (c *Client) BulkOperation(ctx context.Context, id string) (*BulkOperation, error) {
+	var resp struct {
+		Node *BulkOperation `json:"node"`
+	}
+
+	variables := map[string]interface{}{"id": id}
+	if err := c.Query(ctx, bulkOperationNode, variables, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Node == nil {
+		return nil, fmt.Errorf("bulk operation %s not found", id)
+	}
+
+	return resp.Node, nil
+}
+
+// WaitBulkOperation polls the bulk operation every interval until it reaches
+// a terminal status or the context is done.
+func (c *Client) WaitBulkOperation(ctx context.Context, id string, interval time.Duration) (*BulkOperation, error) {
+	if interval <= 0 {
+		interval = DefaultBulkPollInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		op, err := c.BulkOperation(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		c.Debugf("bulk operation %s is %s with %s objects", op.ID, op.Status, op.ObjectCount)
+		if op.Done() {
+			return op, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return op, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// DownloadBulkOperation opens the JSONL result of a completed bulk operation.
+// The returned body is streamed and must be closed by the caller. A completed
+// operation that matched no objects has no URL; an empty reader is returned.
+func (c *Client) DownloadBulkOperation(ctx context.Context, op *BulkOperation) (io.ReadCloser, error) {
+	if op.Status != BulkOperationCompleted {
+		return nil, fmt.Errorf("bulk operation %s is %s", op.ID, op.Status)
+	}
+
+	if op.URL == nil {
+		return http.NoBody, nil
+	}
+
+	// the result URL is pre-signed and served from a different host, so
+	// the request is made without credentials and the body is not logged
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *op.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add(hUserAgent, c.UserAgent())
+
+	c.Infof("%s: %s", req.Method, req.URL.Host+req.URL.Path)
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckResponseError(res); err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+
+	return res.Body, nil
+}
@@ -0,0 +1,561 @@
+package shopify
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/demosdemon/shop/pkg/retry"
+)
+
+// Redacted replaces masked strings in a cassette.
+const Redacted = "[REDACTED]"
+
+// ErrNoInteraction is returned by a Replayer for a request that is not in its
+// cassette, or whose recorded responses have all been replayed.
+var ErrNoInteraction = errors.New("no recorded interaction for request")
+
+// sensitiveHeaders are masked in both requests and responses.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Shopify-Access-Token",
+}
+
+// piiKeys are JSON object keys whose values are masked wherever they appear
+// in a body.
+var piiKeys = map[string]bool{
+	"address1":      true,
+	"address2":      true,
+	"browser_ip":    true,
+	"city":          true,
+	"company":       true,
+	"contact_email": true,
+	"email":         true,
+	"first_name":    true,
+	"last_name":     true,
+	"latitude":      true,
+	"longitude":     true,
+	"phone":         true,
+	"province":      true,
+	"zip":           true,
+}
+
+// addressKeys hold addresses, whose name is the addressee's. Elsewhere name
+// is an order number, a product option and the like, and is kept.
+var addressKeys = map[string]bool{
+	"addresses":        true,
+	"billing_address":  true,
+	"default_address":  true,
+	"shipping_address": true,
+}
+
+// urlKeys hold URLs, such as the pre-signed download URL of a bulk operation,
+// whose signing parameters are masked.
+var urlKeys = map[string]bool{
+	"partialDataUrl": true,
+	"url":            true,
+}
+
+// signingParams are query parameters that authorize a pre-signed URL.
+var signingParams = []string{
+	"GoogleAccessId",
+	"Signature",
+	"X-Amz-Credential",
+	"X-Amz-Security-Token",
+	"X-Amz-Signature",
+	"X-Goog-Credential",
+	"X-Goog-Signature",
+}
+
+// maxInlineBody is the largest response body kept in the cassette itself.
+// Larger bodies, and streamed bodies that are not plain JSON such as bulk
+// operation results, are written to a file next to the cassette as they are
+// read.
+const maxInlineBody = 1 << 20
+
+// Interaction is a request and the response it received, as stored in a
+// cassette.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+
+	// BodyFile names the file next to the cassette that holds the body
+	// instead of Body.
+	BodyFile string `json:"body_file,omitempty"`
+}
+
+// key identifies the request when replaying. The time bounds in its query
+// are left out: they come from the clock or from an earlier run, such as the
+// end of a backfill's last shard or the last check for deletions, and would
+// never match on replay. Requests that differ only by them are answered in
+// the order they were recorded.
+func (r *RecordedRequest) key() string {
+	return r.Method + " " + withoutTimeBounds(r.URL) + "\n" + r.Body
+}
+
+// withoutTimeBounds blanks the *_at_min and *_at_max parameters of rawURL.
+func withoutTimeBounds(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+
+	q := u.Query()
+	for k := range q {
+		if strings.HasSuffix(k, "_at_min") || strings.HasSuffix(k, "_at_max") {
+			q[k] = []string{""}
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Recorder saves every request a client makes, and the response it received,
+// to a cassette file with one JSON interaction per line. Credentials and
+// customer PII are masked before anything is written. An interaction whose
+// body is streamed to its own file is written once the body has been read to
+// the end or closed.
+type Recorder struct {
+	mu     sync.Mutex
+	path   string
+	fp     *os.File
+	enc    *json.Encoder
+	bodies int
+}
+
+// NewRecorder creates or truncates the cassette at path.
+func NewRecorder(path string) (*Recorder, error) {
+	fp, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{path: path, fp: fp, enc: json.NewEncoder(fp)}, nil
+}
+
+// Close closes the cassette file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fp.Close()
+}
+
+// Transport returns a round tripper that records the requests it sends
+// through next. A nil next uses http.DefaultTransport.
+func (r *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		recorded, err := recordRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		// masking changes the length of the body
+		header := maskHeader(res.Header)
+		header.Del("Content-Length")
+
+		interaction := &Interaction{
+			Request: recorded,
+			Response: RecordedResponse{
+				Status: res.StatusCode,
+				Header: header,
+			},
+		}
+
+		if streamed(res) {
+			if err := r.stream(res, interaction); err != nil {
+				_ = res.Body.Close()
+				return nil, errors.Wrap(err, "error recording interaction")
+			}
+			return res, nil
+		}
+
+		body, err := ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		res.Body = ioutil.NopCloser(bytes.NewReader(body))
+		interaction.Response.Body = maskBody(body)
+
+		if err := r.record(interaction); err != nil {
+			return nil, err
+		}
+
+		return res, nil
+	})
+}
+
+func (r *Recorder) record(interaction *Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(interaction); err != nil {
+		return errors.Wrap(err, "error recording interaction")
+	}
+	return nil
+}
+
+// streamed reports whether the body of res is recorded to its own file.
+func streamed(res *http.Response) bool {
+	if res.ContentLength > maxInlineBody {
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	return res.ContentLength < 0 && mediaType != "application/json"
+}
+
+// stream replaces the body of res with one that masks each line it reads into
+// a file next to the cassette, and records interaction when it is done.
+func (r *Recorder) stream(res *http.Response, interaction *Interaction) error {
+	r.mu.Lock()
+	r.bodies++
+	name := fmt.Sprintf("%s.%d.body", filepath.Base(r.path), r.bodies)
+	r.mu.Unlock()
+
+	fp, err := os.Create(filepath.Join(filepath.Dir(r.path), name))
+	if err != nil {
+		return err
+	}
+
+	interaction.Response.BodyFile = name
+	res.Body = &recordingBody{
+		ReadCloser:  res.Body,
+		recorder:    r,
+		interaction: interaction,
+		fp:          fp,
+		w:           bufio.NewWriter(fp),
+	}
+	return nil
+}
+
+// recordingBody masks the lines of a response body into a file as the body
+// is read.
+type recordingBody struct {
+	io.ReadCloser
+	recorder    *Recorder
+	interaction *Interaction
+	fp          *os.File
+	w           *bufio.Writer
+
+	line []byte
+	err  error
+	done bool
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.write(p[:n])
+	if err == io.EOF {
+		if fErr := b.finish(); fErr != nil {
+			return n, fErr
+		}
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if fErr := b.finish(); err == nil {
+		err = fErr
+	}
+	return err
+}
+
+func (b *recordingBody) write(p []byte) {
+	for len(p) > 0 && b.err == nil {
+		idx := bytes.IndexByte(p, '\n')
+		if idx < 0 {
+			b.line = append(b.line, p...)
+			return
+		}
+
+		b.line = append(b.line, p[:idx]...)
+		b.flushLine(true)
+		p = p[idx+1:]
+	}
+}
+
+func (b *recordingBody) flushLine(newline bool) {
+	masked := strings.TrimSuffix(maskBody(b.line), "\n")
+	if newline {
+		masked += "\n"
+	}
+	if _, err := b.w.WriteString(masked); err != nil && b.err == nil {
+		b.err = err
+	}
+	b.line = b.line[:0]
+}
+
+// finish writes the rest of the body and records the interaction. A body
+// that was closed early is recorded as far as it was read.
+func (b *recordingBody) finish() error {
+	if b.done {
+		return b.err
+	}
+	b.done = true
+
+	if len(b.line) > 0 {
+		b.flushLine(false)
+	}
+	if err := b.w.Flush(); err != nil && b.err == nil {
+		b.err = err
+	}
+	if err := b.fp.Close(); err != nil && b.err == nil {
+		b.err = err
+	}
+	if b.err != nil {
+		return errors.Wrap(b.err, "error recording response body")
+	}
+
+	b.err = b.recorder.record(b.interaction)
+	return b.err
+}
+
+// Replayer answers requests from a cassette written by a Recorder without
+// touching the network. A request matches an interaction with the same
+// method, URL and body once credentials and PII are masked and time bounds
+// are left out; repeated requests are answered with their recorded responses
+// in order. A request that matches nothing fails permanently, since retrying
+// it cannot help.
+type Replayer struct {
+	mu           sync.Mutex
+	dir          string
+	interactions map[string][]*Interaction
+}
+
+// NewReplayer loads the cassette at path.
+func NewReplayer(path string) (*Replayer, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fp.Close() }()
+
+	r := &Replayer{dir: filepath.Dir(path), interactions: make(map[string][]*Interaction)}
+	dec := json.NewDecoder(bufio.NewReader(fp))
+	for dec.More() {
+		interaction := new(Interaction)
+		if err := dec.Decode(interaction); err != nil {
+			return nil, errors.Wrapf(err, "error decoding cassette %s", path)
+		}
+
+		key := interaction.Request.key()
+		r.interactions[key] = append(r.interactions[key], interaction)
+	}
+
+	return r, nil
+}
+
+// Transport returns a round tripper that answers requests from the cassette.
+func (r *Replayer) Transport() http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		recorded, err := recordRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		key := recorded.key()
+		r.mu.Lock()
+		queue := r.interactions[key]
+		if len(queue) == 0 {
+			r.mu.Unlock()
+			return nil, retry.Permanent(errors.Wrapf(ErrNoInteraction, "%s %s", recorded.Method, recorded.URL))
+		}
+		interaction := queue[0]
+		r.interactions[key] = queue[1:]
+		r.mu.Unlock()
+
+		res := &http.Response{
+			Status:     fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+			StatusCode: interaction.Response.Status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     interaction.Response.Header.Clone(),
+			Request:    req,
+		}
+
+		if name := interaction.Response.BodyFile; name != "" {
+			fp, err := os.Open(filepath.Join(r.dir, name))
+			if err != nil {
+				return nil, err
+			}
+			res.Body = fp
+			res.ContentLength = -1
+			return res, nil
+		}
+
+		body := interaction.Response.Body
+		res.Body = ioutil.NopCloser(strings.NewReader(body))
+		res.ContentLength = int64(len(body))
+		return res, nil
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// recordRequest returns the masked form of req, leaving its body readable.
+func recordRequest(req *http.Request) (RecordedRequest, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var rc io.ReadCloser
+		var err error
+		if req.GetBody != nil {
+			rc, err = req.GetBody()
+		} else {
+			rc = req.Body
+		}
+		if err != nil {
+			return RecordedRequest{}, err
+		}
+
+		body, err = ioutil.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			return RecordedRequest{}, err
+		}
+
+		if req.GetBody == nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+	}
+
+	u := cloneURL(req.URL)
+	u.User = nil
+	return RecordedRequest{
+		Method: req.Method,
+		URL:    maskURL(u.String()),
+		Header: maskHeader(req.Header),
+		Body:   maskBody(body),
+	}, nil
+}
+
+func maskHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, k := range sensitiveHeaders {
+		if _, ok := header[k]; ok {
+			header[k] = []string{Redacted}
+		}
+	}
+	return header
+}
+
+// maskBody masks the PII in a JSON or JSON lines body. Anything else is
+// returned unchanged.
+func maskBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return string(body)
+	}
+
+	var buf bytes.Buffer
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for dec.More() {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return string(body)
+		}
+		if err := enc.Encode(maskValue(v, false)); err != nil {
+			return string(body)
+		}
+	}
+
+	return buf.String()
+}
+
+// maskValue replaces the values of PII keys in v, keeping their JSON type so
+// replayed bodies still decode into the same structs. address is set inside
+// an address.
+func maskValue(v interface{}, address bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			switch {
+			case piiKeys[k] || (address && k == "name"):
+				switch e.(type) {
+				case string:
+					v[k] = Redacted
+				case json.Number:
+					v[k] = json.Number("0")
+				}
+			case urlKeys[k]:
+				if s, ok := e.(string); ok {
+					v[k] = maskURL(s)
+				}
+			default:
+				v[k] = maskValue(e, addressKeys[k])
+			}
+		}
+	case []interface{}:
+		for idx, e := range v {
+			v[idx] = maskValue(e, address)
+		}
+	}
+	return v
+}
+
+// maskURL masks the signing parameters of a pre-signed URL. Other URLs are
+// returned unchanged.
+func maskURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.RawQuery == "" {
+		return s
+	}
+
+	q := u.Query()
+	masked := false
+	for _, k := range signingParams {
+		if _, ok := q[k]; ok {
+			q[k] = []string{Redacted}
+			masked = true
+		}
+	}
+	if !masked {
+		return s
+	}
+
+	u.RawQuery = q.Encode()
+	return u.String()
+}
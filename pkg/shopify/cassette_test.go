@@ -0,0 +1,178 @@
+package shopify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/demosdemon/shop/pkg/retry"
+	"github.com/demosdemon/shop/pkg/shopify"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+func cassettePath(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return filepath.Join(dir, "test-store.cassette.jsonl")
+}
+
+func TestRecorderMasksPIIAndSignedURLs(t *testing.T) {
+	const body = `{
+		"order": {
+			"id": 1,
+			"name": "#1001",
+			"email": "jane@example.com",
+			"customer": {"id": 7, "first_name": "Jane", "last_name": "Doe"},
+			"shipping_address": {"name": "Jane Doe", "address1": "1 Main St", "zip": "12345", "latitude": 45.5},
+			"line_items": [{"name": "Widget"}]
+		},
+		"url": "https://storage.example.com/bulk.jsonl?X-Amz-Signature=amz-signature&X-Amz-Credential=amz-credential&response-content-type=application%2Fjsonl"
+	}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=cookie-value")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	path := cassettePath(t)
+	recorder, err := shopify.NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/admin/api/2020-01/orders/1.json", nil)
+	req.SetBasicAuth(shopifytest.Username, shopifytest.Password)
+	req.Header.Set("X-Shopify-Access-Token", shopifytest.AccessToken)
+	res, err := (&http.Client{Transport: recorder.Transport(nil)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the cassette is masked
+	if !strings.Contains(string(got), "jane@example.com") {
+		t.Errorf("response body was masked: %s", got)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cassette := string(buf)
+	for _, secret := range []string{"jane@example.com", "Jane", "Doe", "1 Main St", "12345", "45.5", "amz-signature", "amz-credential", "cookie-value", shopifytest.AccessToken} {
+		if strings.Contains(cassette, secret) {
+			t.Errorf("cassette contains %q:\n%s", secret, cassette)
+		}
+	}
+	// names outside of addresses and unsigned parameters are kept
+	for _, kept := range []string{"#1001", "Widget", "response-content-type=application%2Fjsonl"} {
+		if !strings.Contains(cassette, kept) {
+			t.Errorf("cassette lacks %q:\n%s", kept, cassette)
+		}
+	}
+
+	var interaction shopify.Interaction
+	if err := json.Unmarshal(buf, &interaction); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"Authorization", "X-Shopify-Access-Token"} {
+		if got := interaction.Request.Header.Get(k); got != shopify.Redacted {
+			t.Errorf("request header %s = %q, want it redacted", k, got)
+		}
+	}
+	if got := interaction.Response.Header.Get("Set-Cookie"); got != shopify.Redacted {
+		t.Errorf("response header Set-Cookie = %q, want it redacted", got)
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	s := shopifytest.NewServer()
+	seedOrders(t, s, 5)
+
+	path := cassettePath(t)
+	recorder, err := shopify.NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// like a backfill's last shard, the window ends when the run started
+	options := func() shopify.ListOptions {
+		return shopify.ListOptions{Limit: 2, CreatedAtMax: time.Now()}
+	}
+	recorded := collectIDs(t, newClient(s, shopify.WithRecorder(recorder)).Iterate("orders", options()))
+	checkIDs(t, recorded, 5)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	requests := s.Requests()
+	s.Close()
+
+	replayer, err := shopify.NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := newClient(s, shopify.WithReplayer(replayer)).Iterate("orders", shopify.ListOptions{Limit: 2, CreatedAtMax: time.Now().Add(time.Hour)})
+	ctx := context.Background()
+	var replayed []int64
+	for it.Next(ctx) {
+		replayed = append(replayed, it.Item().ID)
+		if email := gjson.GetBytes(it.Item().Raw, "email").String(); email != shopify.Redacted {
+			t.Errorf("replayed email = %q, want it redacted", email)
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, replayed, 5)
+	if it.Page() != requests {
+		t.Errorf("replayed %d pages, recorded %d", it.Page(), requests)
+	}
+}
+
+func TestReplayMissIsPermanent(t *testing.T) {
+	path := cassettePath(t)
+	if err := ioutil.WriteFile(path, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	replayer, err := shopify.NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := shopifytest.NewServer()
+	defer s.Close()
+
+	it := newClient(s, shopify.WithReplayer(replayer)).Iterate("orders", shopify.ListOptions{})
+	if it.Next(context.Background()) {
+		t.Fatal("Next() = true")
+	}
+	if !errors.Is(it.Err(), shopify.ErrNoInteraction) {
+		t.Errorf("Err() = %v, want ErrNoInteraction", it.Err())
+	}
+	if retry.IsRetryable(it.Err()) {
+		t.Errorf("Err() = %v is retryable", it.Err())
+	}
+	if got := s.Requests(); got != 0 {
+		t.Errorf("Requests() = %d, want none", got)
+	}
+}
@@ -3,13 +3,11 @@ package shopify
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"math"
-	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
@@ -19,19 +17,18 @@ import (
 
 	"github.com/google/go-querystring/query"
 	"github.com/peterhellberg/link"
+	"github.com/pkg/errors"
 
-	"github.com/demosdemon/shop/pkg/data"
 	"github.com/demosdemon/shop/pkg/log"
 	"github.com/demosdemon/shop/pkg/retry"
 )
 
 const (
-	DefaultAPIVersion  = "2020-04"
-	DefaultUserAgent   = "shop/1.0.0"
-	DefaultHTTPTimeout = 5 * time.Minute
-	DefaultRetryCount  = 10
-	DefaultRetryDelay  = 100 * time.Millisecond
-	DefaultRetryJitter = 100 * time.Millisecond
+	DefaultAPIVersion   = "2020-04"
+	DefaultUserAgent    = "shop/1.0.0"
+	DefaultHTTPTimeout  = 5 * time.Minute
+	DefaultRetryCount   = 10
+	DefaultRetryBackoff = "full-jitter:100ms:30s"
 
 	fmtBaseURL = "https://%s.myshopify.com"
 	pathPrefix = "admin/api"
@@ -39,6 +36,9 @@ const (
 	mApplicationJSON = "application/json"
 
 	hAPICallLimit = "X-Shopify-Shop-Api-Call-Limit"
+	hAPIVersion   = "X-Shopify-API-Version"
+	hRequestID    = "X-Request-Id"
+	hAccessToken  = "X-Shopify-Access-Token"
 	hAccept       = "Accept"
 	hContentType  = "Content-Type"
 	hRetryAfter   = "Retry-After"
@@ -53,6 +53,11 @@ func New(storeID, username, password string, options ...Option) *Client {
 		password: password,
 	}
 
+	c.Orders = &OrderService{resourceService{client: c, element: "orders", singular: "order"}}
+	c.Products = &ProductService{resourceService{client: c, element: "products", singular: "product"}}
+	c.Customers = &CustomerService{resourceService{client: c, element: "customers", singular: "customer"}}
+	c.Webhooks = &WebhookService{resourceService{client: c, element: "webhooks", singular: "webhook"}}
+
 	for _, opt := range options {
 		opt(c)
 	}
@@ -63,21 +68,32 @@ func New(storeID, username, password string, options ...Option) *Client {
 type Client struct {
 	http.Client
 
+	Orders    *OrderService
+	Products  *ProductService
+	Customers *CustomerService
+	Webhooks  *WebhookService
+
 	storeID  string
 	username string
 	password string
 
-	apiVersion  *string
-	userAgent   *string
-	retryCount  *int
-	retryDelay  *time.Duration
-	retryJitter *time.Duration
-	logger      log.Logger
-
-	rateLimitInfo RateLimitInfo
+	baseURL         *string
+	accessToken     *string
+	apiVersion      *string
+	userAgent       *string
+	retryCount      *int
+	retryBackoff    retry.Backoff
+	retryMaxElapsed *time.Duration
+	attemptTimeout  *time.Duration
+	breaker         *retry.Breaker
+	rateLimiter     *RateLimiter
+	logger          log.Logger
 }
 
 func (c *Client) BaseURL() (*url.URL, error) {
+	if s := c.baseURL; s != nil {
+		return url.Parse(*s)
+	}
 	s := fmt.Sprintf(fmtBaseURL, c.storeID)
 	return url.Parse(s)
 }
@@ -110,22 +126,51 @@ func (c *Client) RetryCount() int {
 	return *i
 }
 
-func (c *Client) RetryDelay() time.Duration {
-	d := c.retryDelay
+// RetryBackoff is the backoff between retries of a failed request. Rate
+// limited requests wait at least as long as Shopify asks.
+func (c *Client) RetryBackoff() retry.Backoff {
+	b := c.retryBackoff
+	if b == nil {
+		b, _ = retry.ParseBackoff(DefaultRetryBackoff, nil)
+	}
+	return b
+}
+
+// RetryMaxElapsed is the longest Do spends on a request, including its
+// retries and the waits between them. Zero means no limit.
+func (c *Client) RetryMaxElapsed() time.Duration {
+	d := c.retryMaxElapsed
 	if d == nil {
-		return DefaultRetryDelay
+		return 0
 	}
 	return *d
 }
 
-func (c *Client) RetryJitter() time.Duration {
-	d := c.retryJitter
+// AttemptTimeout is the deadline of each attempt of a request, from waiting
+// for the rate limiter until its response body is closed. Zero means no
+// deadline beyond the HTTP client's timeout.
+func (c *Client) AttemptTimeout() time.Duration {
+	d := c.attemptTimeout
 	if d == nil {
-		return DefaultRetryJitter
+		return 0
 	}
 	return *d
 }
 
+// Breaker returns the circuit breaker shared by the store's requests, or nil
+// if there is none.
+func (c *Client) Breaker() *retry.Breaker {
+	return c.breaker
+}
+
+func (c *Client) RateLimiter() *RateLimiter {
+	l := c.rateLimiter
+	if l == nil {
+		return SharedRateLimiter(c.storeID)
+	}
+	return l
+}
+
 func (c *Client) Deserialize(res *http.Response, resource interface{}) error {
 	buf, err := ioutil.ReadAll(res.Body)
 	if err != nil {
@@ -140,83 +185,48 @@ func (c *Client) Deserialize(res *http.Response, resource interface{}) error {
 	return nil
 }
 
+// Paginate streams every item of element over a channel. The channel is fed
+// by a goroutine that exits when the pages are exhausted, an error is sent,
+// or the context is done; consumers that stop reading early must cancel the
+// context. Prefer Iterate, which has no such requirement.
 func (c *Client) Paginate(ctx context.Context, element string, options interface{}) (<-chan PaginationResult, error) {
-	limit, ok := getLimit(options)
-	if !ok {
-		limit = 50
-	}
-
-	count, err := c.Count(ctx, c.Path(element), options)
-	if err != nil {
-		return nil, err
+	it := c.Iterate(element, options)
+	count := -1
+	if it.resource.Countable {
+		var err error
+		if count, err = it.Count(ctx); err != nil {
+			return nil, err
+		}
+		c.Infof("expecting %d records", count)
 	}
-	c.Infof("expecting %d records", count)
-
-	pages := int(math.Ceil(float64(count) / float64(limit)))
 
 	ch := make(chan PaginationResult)
-	records := 0
 	go func() {
 		defer close(ch)
-		relPath := c.Path(element) + ".json"
-
-		page := 0
-		for {
-			page++
-			c.Infof("fetching %s page %d of %d", element, page, pages)
-			res, err := c.Get(ctx, relPath, options)
-			if err == context.Canceled || err == context.DeadlineExceeded {
-				return
-			}
+		defer func() { _ = it.Close() }()
 
-			if err != nil {
-				ch <- PaginationResult{err: err}
+		for it.Next(ctx) {
+			select {
+			case ch <- PaginationResult{item: it.Item()}:
+			case <-ctx.Done():
 				return
 			}
+		}
 
-			var resource map[string][]json.RawMessage
-			if err := c.Deserialize(res, &resource); err != nil {
-				ch <- PaginationResult{err: err}
-				return
-			}
-
-			values := resource[element]
-			for _, value := range values {
-				records++
-				item := new(data.Item)
-				err := json.Unmarshal(value, item)
-				ch <- PaginationResult{item: item, err: NewResponseDecodingError(res, err, value)}
-			}
-
-			options, err = getNextPageOptions(res)
-			if err != nil {
-				header := []byte(url.Values(res.Header).Encode())
-				err := NewResponseDecodingError(res, err, header)
-				ch <- PaginationResult{err: err}
+		if err := it.Err(); err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 				return
 			}
 
-			if options == nil {
-				if count != records {
-					c.Warnf("expected %d records but got %d", count, records)
-				}
-				return
+			select {
+			case ch <- PaginationResult{err: err}:
+			case <-ctx.Done():
 			}
+			return
+		}
 
-			if v, ok := options.(url.Values); ok {
-				if v == nil {
-					return
-				}
-
-				if pi := v.Get("page_info"); pi != "" {
-					dec, err := base64.RawStdEncoding.DecodeString(pi)
-					if err != nil {
-						c.Warnf("error decoding page info: %v", err)
-						continue
-					}
-					c.Debugf("next page info: %s", string(dec))
-				}
-			}
+		if records := it.Records(); count >= 0 && count != records {
+			c.Warnf("expected %d records but got %d", count, records)
 		}
 	}()
 
@@ -300,6 +310,7 @@ func (c *Client) NewRequest(ctx context.Context, method, path string, body, opti
 		return nil, err
 	}
 
+	ctx = withRequestContext(ctx, c.storeID, c.APIVersion())
 	req, err := http.NewRequestWithContext(ctx, method, u.String(), b)
 	if err != nil {
 		return nil, err
@@ -309,7 +320,11 @@ func (c *Client) NewRequest(ctx context.Context, method, path string, body, opti
 	req.Header.Add(hAccept, mApplicationJSON)
 	req.Header.Add(hUserAgent, c.UserAgent())
 	if u.Host == baseUrl.Host {
-		req.SetBasicAuth(c.username, c.password)
+		if c.accessToken != nil {
+			req.Header.Set(hAccessToken, *c.accessToken)
+		} else {
+			req.SetBasicAuth(c.username, c.password)
+		}
 	}
 
 	return req, nil
@@ -317,43 +332,121 @@ func (c *Client) NewRequest(ctx context.Context, method, path string, body, opti
 
 func (c *Client) Do(req *http.Request) (res *http.Response, err error) {
 	retryCount := c.RetryCount()
-	delay := delay(c.RetryDelay(), c.RetryJitter())
+	limiter := c.RateLimiter()
+	breaker := c.Breaker()
 	c.logRequest(req)
 
-	err = retry.Go(
-		func() (err error) {
-			res, err = c.Client.Do(req)
-			c.logResponse(res)
-			if err == nil {
-				err = CheckResponseError(res)
-			}
-			if err := c.rateLimitInfo.update(res); err != nil {
-				c.Warnf("error updating rate limit info: %v", err)
-			}
-			return
+	err = retry.GoContext(
+		req.Context(),
+		func(ctx context.Context) (err error) {
+			res, err = c.attempt(ctx, req, limiter, breaker)
+			return classify(err)
 		},
+		retry.WithMaxElapsedTime(c.RetryMaxElapsed()),
+		retry.WithAttemptTimeout(c.AttemptTimeout()),
+		retry.WithBackoff(c.RetryBackoff()),
 		retry.WithMaxAttempts(retryCount),
 		retry.WithOnRetry(func(attempt int, wait time.Duration, err error) {
 			c.Infof("attempt %d/%d: %v; sleeping %s", attempt, retryCount, err, wait)
 		}),
-		retry.WithDoRetryWithDelay(func(attempt int, err error) (time.Duration, bool) {
-			if err == context.Canceled || err == context.DeadlineExceeded {
-				return 0, false
-			}
-			if rateLimitErr, ok := err.(RateLimitError); ok {
-				return rateLimitErr.RetryAfter, ok
-			}
-			if resErr, ok := err.(ResponseError); ok {
-				return delay(attempt),
-					!(http.StatusBadRequest <= resErr.Status && resErr.Status < http.StatusInternalServerError)
-			}
-			return delay(attempt), true
-		}),
 	)
 
 	return
 }
 
+func (c *Client) attempt(ctx context.Context, req *http.Request, limiter *RateLimiter, breaker *retry.Breaker) (*http.Response, error) {
+	if breaker != nil {
+		if err := breaker.Allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	// GraphQL queries draw on a cost bucket of their own, whose throttled
+	// queries graphQL waits out, so they neither wait for nor fill the REST
+	// bucket
+	if isGraphQLURL(req.URL) {
+		limiter = nil
+	}
+
+	if limiter != nil {
+		start := time.Now()
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		if waited := time.Since(start); waited > time.Millisecond {
+			c.Debugf("rate limited; waited %s", waited)
+		}
+	}
+
+	r, err := rewind(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.Client.Do(r)
+	c.logResponse(res)
+	if err == nil {
+		err = CheckResponseError(res)
+	}
+	if breaker != nil {
+		// an attempt that timed out is a failure, unless the request
+		// itself was cancelled
+		record(req.Context(), breaker, err)
+	}
+	if limiter != nil {
+		var info RateLimitInfo
+		if err := info.update(res); err != nil {
+			c.Warnf("error updating rate limit info: %v", err)
+		} else {
+			limiter.Update(info)
+		}
+	}
+
+	// the body is read after the attempt returns
+	if err == nil {
+		res.Body = cancelBody{ReadCloser: res.Body, cancel: retry.Detach(ctx)}
+	}
+	return res, err
+}
+
+// cancelBody cancels the context of the attempt that received it once it is
+// closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// classify marks err for the retry package: rate limited requests wait as
+// long as Shopify asks, while client errors and an open breaker are
+// permanent. Everything else is retried, including attempts that timed out;
+// retry.DoContext stops by itself once the request's context is done.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, retry.ErrBreakerOpen) {
+		return retry.Permanent(err)
+	}
+
+	var rateLimitErr RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return retry.RetryAfter(err, rateLimitErr.RetryAfter)
+	}
+
+	var resErr ResponseError
+	if errors.As(err, &resErr) && http.StatusBadRequest <= resErr.Status && resErr.Status < http.StatusInternalServerError {
+		return retry.Permanent(err)
+	}
+
+	return err
+}
+
 func (c *Client) logRequest(req *http.Request) {
 	if req == nil {
 		return
@@ -416,13 +509,48 @@ func (c *Client) Tracef(format string, v ...interface{}) {
 	c.Logf(log.LevelTrace, format, v...)
 }
 
-func delay(initial, jitter time.Duration) func(step int) time.Duration {
-	return func(step int) time.Duration {
-		n := initial
-		n *= 1 << step
-		n += time.Duration(rand.Int63n(int64(jitter)))
-		return n
+// record reports the outcome of an attempt to the breaker. Server errors and
+// timeouts are failures; an attempt abandoned because ctx is done is neither.
+func record(ctx context.Context, breaker *retry.Breaker, err error) {
+	if err == nil {
+		breaker.Success()
+		return
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	var resErr ResponseError
+	if errors.As(err, &resErr) {
+		if resErr.Status >= http.StatusInternalServerError {
+			breaker.Failure()
+		} else {
+			breaker.Success()
+		}
+		return
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		breaker.Failure()
+	}
+}
+
+// rewind returns a copy of req for another attempt with ctx and a fresh body,
+// since an earlier attempt may have consumed it.
+func rewind(ctx context.Context, req *http.Request) (*http.Request, error) {
+	r := req.WithContext(ctx)
+	if req.GetBody == nil || req.Body == nil || req.Body == http.NoBody {
+		return r, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
 	}
+	r.Body = body
+	return r, nil
 }
 
 func appendQuery(u *url.URL, v interface{}) (*url.URL, error) {
@@ -430,13 +558,9 @@ func appendQuery(u *url.URL, v interface{}) (*url.URL, error) {
 		return u, nil
 	}
 
-	q, err := query.Values(v)
+	q, err := values(v)
 	if err != nil {
-		var ok bool
-		q, ok = v.(url.Values)
-		if !ok {
-			return nil, err
-		}
+		return nil, err
 	}
 
 	for k, values := range u.Query() {
@@ -537,11 +661,34 @@ func getLimit(options interface{}) (limit int, ok bool) {
 		return
 	}
 
-	q, err := query.Values(options)
+	q, err := values(options)
 	if err != nil {
-		q, ok = options.(url.Values)
+		return
 	}
 	limit, err = strconv.Atoi(q.Get("limit"))
 	ok = err == nil
 	return
 }
+
+// values returns a copy of options as url.Values. Options are either a
+// struct with `url` tags or url.Values.
+func values(options interface{}) (url.Values, error) {
+	if options == nil {
+		return url.Values{}, nil
+	}
+
+	q, err := query.Values(options)
+	if err != nil {
+		v, ok := options.(url.Values)
+		if !ok {
+			return nil, err
+		}
+
+		q = make(url.Values, len(v))
+		for k, v := range v {
+			q[k] = append([]string(nil), v...)
+		}
+	}
+
+	return q, nil
+}
@@ -0,0 +1,204 @@
+package shopify_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/demosdemon/shop/pkg/retry"
+	"github.com/demosdemon/shop/pkg/shopify"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+func TestRetriesServerErrors(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 4)
+
+	s.Inject(shopifytest.Fault{Path: "orders", Status: http.StatusInternalServerError, Count: 2})
+
+	checkIDs(t, collectIDs(t, newClient(s).Iterate("orders", shopify.ListOptions{Limit: 2})), 4)
+	if got := s.Requests(); got != 4 {
+		t.Errorf("Requests() = %d, want 4", got)
+	}
+}
+
+func TestGivesUpAfterRetryCount(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 4)
+
+	s.Inject(shopifytest.Fault{Path: "orders", Status: http.StatusBadGateway})
+
+	it := newClient(s).Iterate("orders", shopify.ListOptions{})
+	if it.Next(context.Background()) {
+		t.Fatal("Next() = true")
+	}
+
+	var resErr shopify.ResponseError
+	if !errors.As(it.Err(), &resErr) || resErr.Status != http.StatusBadGateway {
+		t.Fatalf("Err() = %v, want a 502 ResponseError", it.Err())
+	}
+	if got := s.Requests(); got != 3 {
+		t.Errorf("Requests() = %d, want 3", got)
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+
+	s.Inject(shopifytest.Fault{Path: "orders", Status: http.StatusNotFound})
+
+	it := newClient(s).Iterate("orders", shopify.ListOptions{})
+	if it.Next(context.Background()) {
+		t.Fatal("Next() = true")
+	}
+	if retry.IsRetryable(it.Err()) {
+		t.Errorf("Err() = %v is retryable", it.Err())
+	}
+	if got := s.Requests(); got != 1 {
+		t.Errorf("Requests() = %d, want 1", got)
+	}
+}
+
+func TestWaitsOutRetryAfter(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 2)
+
+	const retryAfter = 100 * time.Millisecond
+	s.Inject(shopifytest.Fault{Path: "orders", Status: http.StatusTooManyRequests, RetryAfter: retryAfter, Count: 1})
+
+	start := time.Now()
+	checkIDs(t, collectIDs(t, newClient(s).Iterate("orders", shopify.ListOptions{})), 2)
+	if elapsed := time.Since(start); elapsed < retryAfter {
+		t.Errorf("retried after %s, want at least %s", elapsed, retryAfter)
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 3)
+
+	// the first attempt hangs past its deadline and is retried
+	s.Inject(shopifytest.Fault{Path: "orders", Delay: time.Minute, Count: 1})
+
+	client := newClient(s, shopify.WithAttemptTimeout(200*time.Millisecond))
+	checkIDs(t, collectIDs(t, client.Iterate("orders", shopify.ListOptions{Limit: 2})), 3)
+	if got := s.Requests(); got != 3 {
+		t.Errorf("Requests() = %d, want 3", got)
+	}
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+
+	s.Inject(shopifytest.Fault{Path: "orders", Status: http.StatusServiceUnavailable})
+
+	client := newClient(s,
+		shopify.WithRetryCount(100),
+		shopify.WithRetryBackoff(retry.Constant(20*time.Millisecond)),
+		shopify.WithRetryMaxElapsed(100*time.Millisecond),
+	)
+
+	start := time.Now()
+	it := client.Iterate("orders", shopify.ListOptions{})
+	if it.Next(context.Background()) {
+		t.Fatal("Next() = true")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %s", elapsed)
+	}
+	if got := s.Requests(); got < 2 || got > 6 {
+		t.Errorf("Requests() = %d, want a handful within the budget", got)
+	}
+}
+
+func TestCancelStopsRetrying(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+
+	s.Inject(shopifytest.Fault{Path: "orders", Status: http.StatusServiceUnavailable})
+
+	client := newClient(s, shopify.WithRetryBackoff(retry.Constant(time.Hour)))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	it := client.Iterate("orders", shopify.ListOptions{})
+	if it.Next(ctx) {
+		t.Fatal("Next() = true")
+	}
+	if !errors.Is(it.Err(), context.DeadlineExceeded) {
+		t.Errorf("Err() = %v, want context.DeadlineExceeded", it.Err())
+	}
+}
+
+func TestOpenBreakerFailsFast(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 1)
+
+	s.Inject(shopifytest.Fault{Path: "orders", Status: http.StatusInternalServerError, Count: 1})
+
+	breaker := retry.NewBreaker(1, time.Hour)
+	client := newClient(s, shopify.WithBreaker(breaker))
+
+	// the first failure opens the breaker, which refuses the retry and the
+	// next request at once instead of waiting out the cool-down
+	start := time.Now()
+	for idx := 0; idx < 2; idx++ {
+		it := client.Iterate("orders", shopify.ListOptions{})
+		if it.Next(context.Background()) {
+			t.Fatal("Next() = true")
+		}
+
+		var openErr retry.BreakerOpenError
+		if !errors.As(it.Err(), &openErr) || !errors.Is(it.Err(), retry.ErrBreakerOpen) {
+			t.Fatalf("request %d: Err() = %v, want a BreakerOpenError", idx+1, it.Err())
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("failed after %s", elapsed)
+	}
+	if got := s.Requests(); got != 1 {
+		t.Errorf("Requests() = %d, want 1", got)
+	}
+}
+
+func TestRateLimiterFollowsCallLimit(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 1)
+	s.BucketSize = 80
+
+	limiter := shopify.NewRateLimiter()
+	checkIDs(t, collectIDs(t, newClient(s, shopify.WithRateLimiter(limiter)).Iterate("orders", shopify.ListOptions{})), 1)
+	if got := limiter.Info(); got.BucketSize != 80 || got.RequestCount != 1 {
+		t.Errorf("Info() = %+v, want 1/80", got)
+	}
+}
+
+func TestGraphQLSkipsRESTLimiter(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+
+	// the REST bucket holds every request back for an hour
+	limiter := shopify.NewRateLimiter()
+	limiter.Update(shopify.RateLimitInfo{RequestCount: 40, BucketSize: 40, RetryAfter: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := newClient(s, shopify.WithRateLimiter(limiter))
+	if _, err := client.RunBulkQuery(ctx, "{ orders { edges { node { id } } } }"); err != nil {
+		t.Fatal(err)
+	}
+	if got := limiter.Info(); got.RequestCount != 40 {
+		t.Errorf("Info() = %+v, want the GraphQL response left out", got)
+	}
+}
@@ -0,0 +1,69 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type CustomerService struct {
+	resourceService
+}
+
+// List returns all customers matching options, following every page.
+func (s *CustomerService) List(ctx context.Context, options interface{}) ([]Customer, error) {
+	var customers []Customer
+	err := s.list(ctx, options, &customers)
+	return customers, err
+}
+
+func (s *CustomerService) Get(ctx context.Context, id int64, options interface{}) (*Customer, error) {
+	customer := new(Customer)
+	if err := s.get(ctx, id, options, customer); err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
+
+type Customer struct {
+	ID                int64     `json:"id"`
+	AdminGraphQLAPIID string    `json:"admin_graphql_api_id"`
+	Email             *string   `json:"email"`
+	Phone             *string   `json:"phone"`
+	FirstName         *string   `json:"first_name"`
+	LastName          *string   `json:"last_name"`
+	State             string    `json:"state"`
+	Note              *string   `json:"note"`
+	Tags              string    `json:"tags"`
+	Currency          string    `json:"currency"`
+	VerifiedEmail     bool      `json:"verified_email"`
+	AcceptsMarketing  bool      `json:"accepts_marketing"`
+	TaxExempt         bool      `json:"tax_exempt"`
+	OrdersCount       int       `json:"orders_count"`
+	TotalSpent        string    `json:"total_spent"`
+	LastOrderID       *int64    `json:"last_order_id"`
+	LastOrderName     *string   `json:"last_order_name"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	DefaultAddress    *Address  `json:"default_address"`
+	Addresses         []Address `json:"addresses"`
+
+	// Raw is the JSON the customer was decoded from.
+	Raw json.RawMessage `json:"-"`
+}
+
+func (c *Customer) UnmarshalJSON(data []byte) error {
+	type customer Customer
+	var v customer
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*c = Customer(v)
+	c.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (c Customer) MarshalJSON() ([]byte, error) {
+	type customer Customer
+	return marshalRaw(c.Raw, customer(c))
+}
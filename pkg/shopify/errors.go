@@ -1,26 +1,181 @@
 package shopify
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"path"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors matched by the response errors with errors.Is.
+var (
+	// ErrUnauthorized matches 401 and 403 responses: the credentials are
+	// invalid or lack a scope.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrShopUnavailable matches 402 and 423 responses: the shop is frozen
+	// for non-payment or locked.
+	ErrShopUnavailable = errors.New("shop unavailable")
+
+	ErrNotFound    = errors.New("not found")
+	ErrRateLimited = errors.New("rate limited")
 )
 
+// StoreCondition is a reason a whole store cannot be synced.
+type StoreCondition string
+
+const (
+	// StoreUnauthorized means the credentials were revoked, or cannot even
+	// read the shop.
+	StoreUnauthorized StoreCondition = "unauthorized"
+
+	// StoreFrozen means the shop is frozen for non-payment.
+	StoreFrozen StoreCondition = "frozen"
+
+	// StoreLocked means the shop is locked, e.g. for fraud review.
+	StoreLocked StoreCondition = "locked"
+)
+
+// StoreConditionOf returns the store-level condition err was caused by, if
+// any. Retrying such an error, or syncing another element of the store, fails
+// the same way. A 403 usually means the credentials lack the scope of one
+// element, which is a failure of that element alone; only a 403 for the shop
+// itself concerns the whole store. A sync never requests the shop, so its 403s
+// are always failures of the element; only callers of Client.Shop, such as the
+// health check, can see a store-level one.
+func StoreConditionOf(err error) (StoreCondition, bool) {
+	var resErr ResponseError
+	if !errors.As(err, &resErr) {
+		return "", false
+	}
+
+	switch resErr.Status {
+	case http.StatusUnauthorized:
+		return StoreUnauthorized, true
+	case http.StatusForbidden:
+		if isShopURL(resErr.URL) {
+			return StoreUnauthorized, true
+		}
+		return "", false
+	case http.StatusPaymentRequired:
+		return StoreFrozen, true
+	case http.StatusLocked:
+		return StoreLocked, true
+	default:
+		return "", false
+	}
+}
+
+// isShopURL reports whether rawURL is the shop endpoint.
+func isShopURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && path.Base(u.Path) == "shop.json"
+}
+
+type requestContextKey struct{}
+
+// requestContext is attached to the context of every request so errors
+// decoded from its response can say which store and API version it was for.
+type requestContext struct {
+	storeID    string
+	apiVersion string
+}
+
+func withRequestContext(ctx context.Context, storeID, apiVersion string) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, requestContext{storeID: storeID, apiVersion: apiVersion})
+}
+
+// newResponseError returns a ResponseError describing res and the request
+// it answers. The URL never includes credentials.
+func newResponseError(res *http.Response) ResponseError {
+	var e ResponseError
+	if res == nil {
+		return e
+	}
+
+	e.Status = res.StatusCode
+	e.RequestID = res.Header.Get(hRequestID)
+	e.APIVersion = res.Header.Get(hAPIVersion)
+
+	if req := res.Request; req != nil {
+		e.Method = req.Method
+		u := cloneURL(req.URL)
+		u.User = nil
+		e.URL = u.String()
+
+		if rc, ok := req.Context().Value(requestContextKey{}).(requestContext); ok {
+			e.StoreID = rc.storeID
+			if e.APIVersion == "" {
+				e.APIVersion = rc.apiVersion
+			}
+		}
+	}
+
+	return e
+}
+
 func NewResponseDecodingError(res *http.Response, err error, data []byte) error {
 	if err == nil {
 		return nil
 	}
 
+	responseError := newResponseError(res)
+	responseError.Message = err.Error()
+	responseError.Err = err
 	return ResponseDecodingError{
-		ResponseError: ResponseError{
-			Status:  res.StatusCode,
-			Message: err.Error(),
-		},
-		Body: data,
+		ResponseError: responseError,
+		Body:          data,
+	}
+}
+
+func NewGraphQLResponseError(res *http.Response, errs []GraphQLError, cost *QueryCost) error {
+	messages := make([]string, len(errs))
+	for idx, e := range errs {
+		messages[idx] = e.Message
+	}
+
+	responseError := newResponseError(res)
+	responseError.Errors = messages
+
+	if isThrottled(errs) {
+		// throttled queries are reported with a 200 status
+		responseError.Status = http.StatusTooManyRequests
+		return RateLimitError{
+			ResponseError: responseError,
+			RetryAfter:    throttleWait(cost),
+		}
+	}
+
+	return GraphQLResponseError{
+		ResponseError: responseError,
+		GraphQLErrors: errs,
+	}
+}
+
+func NewMutationError(res *http.Response, mutation string, errs []UserError) error {
+	messages := make([]string, len(errs))
+	for idx, e := range errs {
+		if len(e.Field) > 0 {
+			messages[idx] = fmt.Sprintf("%s: %s", strings.Join(e.Field, "."), e.Message)
+		} else {
+			messages[idx] = e.Message
+		}
+	}
+
+	responseError := newResponseError(res)
+	responseError.Errors = messages
+	return MutationError{
+		ResponseError: responseError,
+		Mutation:      mutation,
+		UserErrors:    errs,
 	}
 }
 
@@ -46,11 +201,8 @@ func CheckResponseError(res *http.Response) error {
 		}
 	}
 
-	responseError := ResponseError{
-		Status:  res.StatusCode,
-		Message: shopifyError.Error,
-	}
-
+	responseError := newResponseError(res)
+	responseError.Message = shopifyError.Error
 	responseError.setErrors(shopifyError.Errors)
 	return wrapSpecificError(res, responseError)
 }
@@ -59,6 +211,20 @@ type ResponseError struct {
 	Status  int
 	Message string
 	Errors  []string
+
+	// FieldErrors are validation errors keyed by field.
+	FieldErrors map[string][]string
+
+	// RequestID is Shopify's X-Request-Id for the response; support needs it
+	// to find the request.
+	RequestID  string
+	Method     string
+	URL        string
+	APIVersion string
+	StoreID    string
+
+	// Err is the underlying cause, if there is one.
+	Err error
 }
 
 type ResponseDecodingError struct {
@@ -71,6 +237,17 @@ type RateLimitError struct {
 	RetryAfter time.Duration
 }
 
+type GraphQLResponseError struct {
+	ResponseError
+	GraphQLErrors []GraphQLError
+}
+
+type MutationError struct {
+	ResponseError
+	Mutation   string
+	UserErrors []UserError
+}
+
 func (e ResponseError) Error() string {
 	const (
 		unknown = "unknown error"
@@ -82,6 +259,9 @@ func (e ResponseError) Error() string {
 	if msg == "" {
 		msg = strings.Join(e.Errors, errSep)
 	}
+	if msg == "" {
+		msg = strings.Join(flattenErrorMap(e.FieldErrors), errSep)
+	}
 	if msg == "" && e.Status > 0 {
 		msg = http.StatusText(e.Status)
 	}
@@ -90,12 +270,51 @@ func (e ResponseError) Error() string {
 	}
 
 	if e.Status > 0 {
-		return fmt.Sprintf(msgFmt, e.Status, msg)
+		msg = fmt.Sprintf(msgFmt, e.Status, msg)
+	}
+	if e.RequestID != "" {
+		msg = fmt.Sprintf("%s (request %s)", msg, e.RequestID)
 	}
 
 	return msg
 }
 
+// Is matches the sentinel errors by status.
+func (e ResponseError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden
+	case ErrShopUnavailable:
+		return e.Status == http.StatusPaymentRequired || e.Status == http.StatusLocked
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound
+	case ErrRateLimited:
+		return e.Status == http.StatusTooManyRequests
+	default:
+		return false
+	}
+}
+
+func (e ResponseError) Unwrap() error {
+	return e.Err
+}
+
+func (e ResponseDecodingError) Unwrap() error {
+	return e.ResponseError
+}
+
+func (e RateLimitError) Unwrap() error {
+	return e.ResponseError
+}
+
+func (e GraphQLResponseError) Unwrap() error {
+	return e.ResponseError
+}
+
+func (e MutationError) Unwrap() error {
+	return e.ResponseError
+}
+
 func (e *ResponseError) setErrors(errors interface{}) {
 	switch errors := errors.(type) {
 	case nil:
@@ -105,7 +324,7 @@ func (e *ResponseError) setErrors(errors interface{}) {
 	case []interface{}:
 		e.Errors = coerceErrorSlice(errors)
 	case map[string]interface{}:
-		e.Errors = coerceErrorMap(errors)
+		e.FieldErrors = coerceErrorMap(errors)
 	default:
 		if e.Message == "" {
 			e.Message = fmt.Sprint(errors)
@@ -127,7 +346,7 @@ func coerceError(v interface{}) string {
 	case []string:
 		return strings.Join(v, sep)
 	case map[string]interface{}:
-		s := coerceErrorMap(v)
+		s := flattenErrorMap(coerceErrorMap(v))
 		return strings.Join(s, sep)
 	default:
 		return fmt.Sprint(v)
@@ -142,7 +361,22 @@ func coerceErrorSlice(v []interface{}) []string {
 	return rv
 }
 
-func coerceErrorMap(v map[string]interface{}) []string {
+func coerceErrorMap(v map[string]interface{}) map[string][]string {
+	rv := make(map[string][]string, len(v))
+	for k, v := range v {
+		switch v := v.(type) {
+		case []interface{}:
+			rv[k] = coerceErrorSlice(v)
+		default:
+			rv[k] = []string{coerceError(v)}
+		}
+	}
+	return rv
+}
+
+func flattenErrorMap(v map[string][]string) []string {
+	const sep = ", "
+
 	keys := make([]string, 0, len(v))
 	for k := range v {
 		keys = append(keys, k)
@@ -150,8 +384,11 @@ func coerceErrorMap(v map[string]interface{}) []string {
 	sort.Strings(keys)
 	rv := make([]string, len(keys))
 	for idx, k := range keys {
-		v := v[k]
-		rv[idx] = fmt.Sprintf("%s: %s", k, coerceError(v))
+		rv[idx] = fmt.Sprintf("%s: %s", k, strings.Join(v[k], sep))
 	}
 	return rv
 }
+
+func (e MutationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Mutation, e.ResponseError.Error())
+}
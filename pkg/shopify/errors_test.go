@@ -0,0 +1,65 @@
+package shopify_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+func TestStoreConditionOf(t *testing.T) {
+	const (
+		ordersURL = "https://test-store.myshopify.com/admin/api/2020-01/orders.json"
+		shopURL   = "https://test-store.myshopify.com/admin/api/2020-01/shop.json"
+	)
+
+	tests := []struct {
+		name   string
+		err    error
+		want   shopify.StoreCondition
+		wantOK bool
+	}{
+		{name: "nil"},
+		{name: "other error", err: errors.New("boom")},
+		{name: "unauthorized", err: shopify.ResponseError{Status: http.StatusUnauthorized, URL: ordersURL}, want: shopify.StoreUnauthorized, wantOK: true},
+		{name: "forbidden element", err: shopify.ResponseError{Status: http.StatusForbidden, URL: ordersURL}},
+		{name: "forbidden shop", err: shopify.ResponseError{Status: http.StatusForbidden, URL: shopURL}, want: shopify.StoreUnauthorized, wantOK: true},
+		{name: "frozen", err: shopify.ResponseError{Status: http.StatusPaymentRequired, URL: ordersURL}, want: shopify.StoreFrozen, wantOK: true},
+		{name: "locked", err: shopify.ResponseError{Status: http.StatusLocked, URL: ordersURL}, want: shopify.StoreLocked, wantOK: true},
+		{name: "server error", err: shopify.ResponseError{Status: http.StatusInternalServerError, URL: ordersURL}},
+		{name: "wrapped", err: errors.Wrap(shopify.ResponseError{Status: http.StatusLocked, URL: ordersURL}, "orders"), want: shopify.StoreLocked, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := shopify.StoreConditionOf(tt.err)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("StoreConditionOf(%v) = %q, %t, want %q, %t", tt.err, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStoreConditionOfRequest(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+
+	s.Inject(shopifytest.Fault{Path: "orders", Status: http.StatusPaymentRequired})
+
+	it := newClient(s).Iterate("orders", shopify.ListOptions{})
+	if it.Next(context.Background()) {
+		t.Fatal("Next() = true")
+	}
+	if got, ok := shopify.StoreConditionOf(it.Err()); got != shopify.StoreFrozen || !ok {
+		t.Errorf("StoreConditionOf(%v) = %q, %t, want frozen", it.Err(), got, ok)
+	}
+
+	// the store condition is not retried
+	if got := s.Requests(); got != 1 {
+		t.Errorf("Requests() = %d, want 1", got)
+	}
+}
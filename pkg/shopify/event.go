@@ -0,0 +1,32 @@
+package shopify
+
+import (
+	"time"
+)
+
+// EventVerbDestroy is the verb of the events recorded when a record is
+// deleted.
+const EventVerbDestroy = "destroy"
+
+type Event struct {
+	ID          int64     `json:"id"`
+	SubjectID   int64     `json:"subject_id"`
+	SubjectType string    `json:"subject_type"`
+	Verb        string    `json:"verb"`
+	Message     string    `json:"message"`
+	Author      string    `json:"author"`
+	Path        string    `json:"path"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// EventListOptions filter the events resource.
+type EventListOptions struct {
+	Limit        int       `url:"limit,omitempty"`
+	SinceID      int64     `url:"since_id,omitempty"`
+	CreatedAtMin time.Time `url:"created_at_min,omitempty"`
+	CreatedAtMax time.Time `url:"created_at_max,omitempty"`
+
+	// Filter is a comma separated list of subject types.
+	Filter string `url:"filter,omitempty"`
+	Verb   string `url:"verb,omitempty"`
+}
@@ -0,0 +1,228 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/demosdemon/shop/pkg/retry"
+)
+
+const (
+	graphQLPath = "graphql.json"
+
+	graphQLThrottled = "THROTTLED"
+)
+
+// ThrottleStatus is the state of the store's GraphQL cost bucket as reported
+// in `extensions.cost.throttleStatus`.
+type ThrottleStatus struct {
+	MaximumAvailable   float64 `json:"maximumAvailable"`
+	CurrentlyAvailable float64 `json:"currentlyAvailable"`
+	RestoreRate        float64 `json:"restoreRate"`
+}
+
+// Wait returns how long it will take for the bucket to restore enough points
+// to afford a query of the given cost.
+func (t ThrottleStatus) Wait(cost float64) time.Duration {
+	if t.RestoreRate <= 0 || cost <= t.CurrentlyAvailable {
+		return 0
+	}
+
+	deficit := cost - t.CurrentlyAvailable
+	return time.Duration(deficit / t.RestoreRate * float64(time.Second))
+}
+
+type QueryCost struct {
+	RequestedQueryCost float64        `json:"requestedQueryCost"`
+	ActualQueryCost    *float64       `json:"actualQueryCost"`
+	ThrottleStatus     ThrottleStatus `json:"throttleStatus"`
+}
+
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Code returns `extensions.code`, if present.
+func (e GraphQLError) Code() string {
+	s, _ := e.Extensions["code"].(string)
+	return s
+}
+
+type UserError struct {
+	Field   []string `json:"field"`
+	Message string   `json:"message"`
+	Code    string   `json:"code,omitempty"`
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data       json.RawMessage `json:"data"`
+	Errors     []GraphQLError  `json:"errors"`
+	Extensions struct {
+		Cost *QueryCost `json:"cost"`
+	} `json:"extensions"`
+}
+
+// Query executes a GraphQL query against the Admin API and decodes `data`
+// into v. Top-level GraphQL errors are returned as a GraphQLResponseError;
+// throttled queries are retried after the bucket has restored enough points.
+func (c *Client) Query(ctx context.Context, query string, variables map[string]interface{}, v interface{}) error {
+	resp, err := c.graphQL(ctx, query, variables)
+	if err != nil {
+		return err
+	}
+
+	return decodeGraphQLData(resp, v)
+}
+
+// Mutate executes a GraphQL mutation like Query, and additionally returns a
+// MutationError if any mutation payload reports `userErrors`. The data is
+// decoded into v even if user errors are present.
+func (c *Client) Mutate(ctx context.Context, mutation string, variables map[string]interface{}, v interface{}) error {
+	resp, err := c.graphQL(ctx, mutation, variables)
+	if err != nil {
+		return err
+	}
+
+	if err := decodeGraphQLData(resp, v); err != nil {
+		return err
+	}
+
+	return checkUserErrors(resp.res, resp.Data)
+}
+
+type graphQLResult struct {
+	graphQLResponse
+	res *http.Response
+}
+
+func (c *Client) graphQL(ctx context.Context, query string, variables map[string]interface{}) (resp *graphQLResult, err error) {
+	retryCount := c.RetryCount()
+
+	// transport and HTTP level errors have already been retried by Do, so only
+	// throttled queries are retried here
+	err = retry.GoContext(
+		ctx,
+		func(ctx context.Context) (err error) {
+			resp, err = c.doGraphQL(ctx, query, variables)
+			var rateLimitErr RateLimitError
+			if errors.As(err, &rateLimitErr) {
+				return retry.RetryAfter(err, rateLimitErr.RetryAfter)
+			}
+			return retry.Permanent(err)
+		},
+		retry.WithMaxAttempts(retryCount),
+		retry.WithMaxElapsedTime(c.RetryMaxElapsed()),
+		retry.WithOnRetry(func(attempt int, wait time.Duration, err error) {
+			c.Infof("graphql attempt %d/%d: %v; sleeping %s", attempt, retryCount, err, wait)
+		}),
+	)
+
+	return
+}
+
+func (c *Client) doGraphQL(ctx context.Context, query string, variables map[string]interface{}) (*graphQLResult, error) {
+	res, err := c.Post(ctx, c.Path(graphQLPath), graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &graphQLResult{res: res}
+	if err := c.Deserialize(res, &resp.graphQLResponse); err != nil {
+		return nil, err
+	}
+
+	cost := resp.Extensions.Cost
+	if cost != nil {
+		c.Debugf(
+			"query cost %.0f, available %.0f/%.0f",
+			cost.RequestedQueryCost,
+			cost.ThrottleStatus.CurrentlyAvailable,
+			cost.ThrottleStatus.MaximumAvailable,
+		)
+	}
+
+	if len(resp.Errors) > 0 {
+		return nil, NewGraphQLResponseError(res, resp.Errors, cost)
+	}
+
+	return resp, nil
+}
+
+// isGraphQLURL reports whether u is the GraphQL endpoint.
+func isGraphQLURL(u *url.URL) bool {
+	return u != nil && path.Base(u.Path) == graphQLPath
+}
+
+func decodeGraphQLData(resp *graphQLResult, v interface{}) error {
+	if v == nil || len(resp.Data) == 0 {
+		return nil
+	}
+
+	return NewResponseDecodingError(resp.res, json.Unmarshal(resp.Data, v), resp.Data)
+}
+
+func checkUserErrors(res *http.Response, data []byte) error {
+	var err error
+
+	gjson.ParseBytes(data).ForEach(func(key, value gjson.Result) bool {
+		userErrors := value.Get("userErrors")
+		if !userErrors.IsArray() || len(userErrors.Array()) == 0 {
+			return true
+		}
+
+		var errs []UserError
+		if dErr := json.Unmarshal([]byte(userErrors.Raw), &errs); dErr != nil {
+			err = NewResponseDecodingError(res, dErr, []byte(userErrors.Raw))
+			return false
+		}
+
+		err = NewMutationError(res, key.String(), errs)
+		return false
+	})
+
+	return err
+}
+
+func throttleWait(cost *QueryCost) time.Duration {
+	const fallback = time.Second
+
+	if cost == nil {
+		return fallback
+	}
+
+	wait := cost.ThrottleStatus.Wait(cost.RequestedQueryCost)
+	if wait <= 0 {
+		return fallback
+	}
+
+	return wait
+}
+
+func isThrottled(errs []GraphQLError) bool {
+	for _, e := range errs {
+		if e.Code() == graphQLThrottled {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package shopify_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/demosdemon/shop/pkg/retry"
+	"github.com/demosdemon/shop/pkg/shopify"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+const bulkQuery = "{ orders { edges { node { id } } } }"
+
+func TestQueryReturnsGraphQLErrors(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+
+	var v struct{}
+	err := newClient(s).Query(context.Background(), "{ shop { name } }", nil, &v)
+
+	var gqlErr shopify.GraphQLResponseError
+	if !errors.As(err, &gqlErr) {
+		t.Fatalf("Query() = %v, want a GraphQLResponseError", err)
+	}
+	if len(gqlErr.GraphQLErrors) != 1 || gqlErr.GraphQLErrors[0].Message != "unsupported query" {
+		t.Errorf("GraphQLErrors = %+v", gqlErr.GraphQLErrors)
+	}
+	if retry.IsRetryable(err) {
+		t.Errorf("IsRetryable(%v) = true", err)
+	}
+	if got := s.Requests(); got != 1 {
+		t.Errorf("Requests() = %d, want 1", got)
+	}
+}
+
+func TestMutateReturnsUserErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		running   bool
+		wantField []string
+	}{
+		{name: "invalid query", query: "not a query", wantField: []string{"query"}},
+		{name: "operation in progress", query: bulkQuery, running: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := shopifytest.NewServer()
+			defer s.Close()
+
+			client := newClient(s)
+			ctx := context.Background()
+			if tt.running {
+				if _, err := client.RunBulkQuery(ctx, bulkQuery); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := client.RunBulkQuery(ctx, tt.query)
+
+			var mutErr shopify.MutationError
+			if !errors.As(err, &mutErr) {
+				t.Fatalf("RunBulkQuery() = %v, want a MutationError", err)
+			}
+			if mutErr.Mutation != "bulkOperationRunQuery" || len(mutErr.UserErrors) != 1 {
+				t.Fatalf("MutationError = %+v", mutErr)
+			}
+			if got := mutErr.UserErrors[0].Field; len(got) != len(tt.wantField) || (len(got) > 0 && got[0] != tt.wantField[0]) {
+				t.Errorf("Field = %v, want %v", got, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestRetriesThrottledQueries(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+
+	const wait = 20 * time.Millisecond
+	s.Inject(shopifytest.Fault{Path: "graphql.json", Throttled: true, RetryAfter: wait, Count: 2})
+
+	start := time.Now()
+	op, err := newClient(s).RunBulkQuery(context.Background(), bulkQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != shopify.BulkOperationCreated {
+		t.Errorf("Status = %s, want %s", op.Status, shopify.BulkOperationCreated)
+	}
+	if got := s.Requests(); got != 3 {
+		t.Errorf("Requests() = %d, want 3", got)
+	}
+	// the wait comes from the restore rate, not the client's backoff
+	if elapsed := time.Since(start); elapsed < 2*wait {
+		t.Errorf("succeeded after %s, want at least %s", elapsed, 2*wait)
+	}
+}
+
+func TestGivesUpOnThrottledQueries(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+
+	s.Inject(shopifytest.Fault{Path: "graphql.json", Throttled: true, RetryAfter: time.Millisecond})
+
+	_, err := newClient(s).RunBulkQuery(context.Background(), bulkQuery)
+
+	var rateLimitErr shopify.RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("RunBulkQuery() = %v, want a RateLimitError", err)
+	}
+	if got := s.Requests(); got != 3 {
+		t.Errorf("Requests() = %d, want 3", got)
+	}
+}
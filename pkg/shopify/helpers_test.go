@@ -0,0 +1,66 @@
+package shopify_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/demosdemon/shop/pkg/retry"
+	"github.com/demosdemon/shop/pkg/shopify"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type testOrder struct {
+	ID        int64  `json:"id"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func seedOrders(t *testing.T, s *shopifytest.Server, n int) {
+	t.Helper()
+	for idx := 1; idx <= n; idx++ {
+		ts := epoch.Add(time.Duration(idx) * time.Hour).Format(time.RFC3339)
+		order := testOrder{ID: int64(idx), Email: fmt.Sprintf("%d@example.com", idx), CreatedAt: ts, UpdatedAt: ts}
+		if err := s.Seed("orders", order); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newClient returns a client with its own rate limiter that retries quickly.
+func newClient(s *shopifytest.Server, options ...shopify.Option) *shopify.Client {
+	options = append([]shopify.Option{
+		shopify.WithRateLimiter(shopify.NewRateLimiter()),
+		shopify.WithRetryBackoff(retry.Constant(time.Millisecond)),
+		shopify.WithRetryCount(3),
+	}, options...)
+	return s.Client("test-store", options...)
+}
+
+func collectIDs(t *testing.T, it *shopify.Iterator) []int64 {
+	t.Helper()
+	var ids []int64
+	for it.Next(context.Background()) {
+		ids = append(ids, it.Item().ID)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func checkIDs(t *testing.T, got []int64, n int) {
+	t.Helper()
+	if len(got) != n {
+		t.Fatalf("got %d ids, want %d: %v", len(got), n, got)
+	}
+	for idx, id := range got {
+		if id != int64(idx+1) {
+			t.Fatalf("ids = %v, want 1..%d", got, n)
+		}
+	}
+}
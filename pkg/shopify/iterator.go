@@ -0,0 +1,217 @@
+package shopify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/demosdemon/shop/pkg/data"
+)
+
+var ErrNotCountable = errors.New("resource does not support count")
+
+// Iterator pages through an element one page at a time. A page is only
+// fetched when Next runs out of items from the previous one, so abandoning an
+// iterator never leaves anything running in the background.
+type Iterator struct {
+	client   *Client
+	resource *Resource
+	relPath  string
+
+	options  interface{}
+	pageInfo string
+	page     int
+	pages    int
+	records  int
+	done     bool
+	closed   bool
+
+	res   *http.Response
+	items []json.RawMessage
+	item  *data.Item
+	err   error
+}
+
+// Iterate pages through the named resource; see ResourceFor.
+func (c *Client) Iterate(element string, options interface{}) *Iterator {
+	return c.IterateResource(ResourceFor(element), options)
+}
+
+// IterateResource pages through resource. The resource's default parameters
+// are added to options unless options resume from a page_info cursor.
+func (c *Client) IterateResource(resource *Resource, options interface{}) *Iterator {
+	it := &Iterator{
+		client:   c,
+		resource: resource,
+		relPath:  c.Path(resource.Path) + ".json",
+		options:  options,
+	}
+
+	if len(resource.Params) > 0 {
+		q, err := values(options)
+		if err != nil {
+			it.err = err
+			return it
+		}
+
+		if q.Get("page_info") == "" {
+			for k, v := range resource.Params {
+				if _, ok := q[k]; !ok {
+					q[k] = v
+				}
+			}
+		}
+		it.options = q
+	}
+
+	return it
+}
+
+// Count returns the number of records the iterator is expected to produce.
+// It must be called before the first call to Next.
+func (it *Iterator) Count(ctx context.Context) (int, error) {
+	if it.err != nil {
+		return 0, it.err
+	}
+
+	if !it.resource.Countable {
+		return 0, ErrNotCountable
+	}
+
+	count, err := it.client.Count(ctx, it.client.Path(it.resource.Path), it.options)
+	if err != nil {
+		return 0, err
+	}
+
+	limit, ok := getLimit(it.options)
+	if !ok {
+		limit = 50
+	}
+	it.pages = int(math.Ceil(float64(count) / float64(limit)))
+
+	return count, nil
+}
+
+// Next advances to the next item, fetching the next page if needed. It
+// returns false when there are no more items or an error occurred.
+func (it *Iterator) Next(ctx context.Context) bool {
+	if it.err != nil || it.closed {
+		return false
+	}
+
+	for len(it.items) == 0 {
+		if it.done {
+			return false
+		}
+
+		if err := it.fetch(ctx); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	value := it.items[0]
+	it.items = it.items[1:]
+
+	item := new(data.Item)
+	if err := item.Decode(value, it.resource.Timestamps); err != nil {
+		it.err = NewResponseDecodingError(it.res, err, value)
+		return false
+	}
+
+	it.item = item
+	it.records++
+	return true
+}
+
+func (it *Iterator) fetch(ctx context.Context) error {
+	c := it.client
+
+	it.page++
+	if it.pages > 0 {
+		c.Infof("fetching %s page %d of %d", it.resource.Name, it.page, it.pages)
+	} else {
+		c.Infof("fetching %s page %d", it.resource.Name, it.page)
+	}
+
+	res, err := c.Get(ctx, it.relPath, it.options)
+	if err != nil {
+		return err
+	}
+
+	var resource map[string][]json.RawMessage
+	if err := c.Deserialize(res, &resource); err != nil {
+		return err
+	}
+
+	it.res = res
+	it.items = resource[it.resource.Key]
+
+	next, err := getNextPageOptions(res)
+	if err != nil {
+		header := []byte(url.Values(res.Header).Encode())
+		return NewResponseDecodingError(res, err, header)
+	}
+
+	if next == nil {
+		it.done = true
+		return nil
+	}
+
+	it.options = next
+	it.pageInfo = next.Get("page_info")
+	if dec, err := base64.RawStdEncoding.DecodeString(it.pageInfo); err == nil {
+		c.Debugf("next page info: %s", string(dec))
+	} else {
+		c.Warnf("error decoding page info: %v", err)
+	}
+
+	return nil
+}
+
+// Item returns the current item.
+func (it *Iterator) Item() *data.Item {
+	return it.item
+}
+
+// Err returns the error that stopped the iterator, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// PageInfo returns the cursor of the next page to fetch, or an empty string
+// if the last page has been fetched.
+func (it *Iterator) PageInfo() string {
+	if it.done {
+		return ""
+	}
+	return it.pageInfo
+}
+
+// Buffered returns the number of items left on the current page. It is zero
+// when the current item is the last one of its page.
+func (it *Iterator) Buffered() int {
+	return len(it.items)
+}
+
+// Page returns the number of pages fetched so far.
+func (it *Iterator) Page() int {
+	return it.page
+}
+
+// Records returns the number of items produced so far.
+func (it *Iterator) Records() int {
+	return it.records
+}
+
+// Close stops the iterator. Subsequent calls to Next return false.
+func (it *Iterator) Close() error {
+	it.closed = true
+	it.items = nil
+	return nil
+}
@@ -0,0 +1,175 @@
+package shopify_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+func TestIteratorPaginates(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 7)
+
+	it := newClient(s).Iterate("orders", shopify.ListOptions{Limit: 3})
+
+	count, err := it.Count(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 7 {
+		t.Errorf("Count() = %d, want 7", count)
+	}
+
+	checkIDs(t, collectIDs(t, it), 7)
+	if it.Page() != 3 {
+		t.Errorf("Page() = %d, want 3", it.Page())
+	}
+	if it.Records() != 7 {
+		t.Errorf("Records() = %d, want 7", it.Records())
+	}
+	if it.PageInfo() != "" {
+		t.Errorf("PageInfo() = %q after the last page", it.PageInfo())
+	}
+}
+
+func TestIteratorDecodesTimestamps(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 1)
+
+	it := newClient(s).Iterate("orders", shopify.ListOptions{})
+	if !it.Next(context.Background()) {
+		t.Fatal(it.Err())
+	}
+	if want := epoch.Add(time.Hour); !it.Item().CreatedAt.Equal(want) || !it.Item().UpdatedAt.Equal(want) {
+		t.Errorf("timestamps = %s, %s, want %s", it.Item().CreatedAt, it.Item().UpdatedAt, want)
+	}
+}
+
+func TestIteratorResumesFromPageInfo(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 5)
+	client := newClient(s)
+
+	it := client.Iterate("orders", shopify.ListOptions{Limit: 2})
+	ctx := context.Background()
+	// stop at the end of the first page
+	for it.Next(ctx) {
+		if it.Buffered() == 0 {
+			break
+		}
+	}
+	if it.Item().ID != 2 {
+		t.Fatalf("stopped at %d, want the end of the first page", it.Item().ID)
+	}
+	pageInfo := it.PageInfo()
+	if pageInfo == "" {
+		t.Fatal("PageInfo() is empty after the first page")
+	}
+	_ = it.Close()
+	if it.Next(ctx) {
+		t.Error("Next() after Close() = true")
+	}
+
+	resumed := client.Iterate("orders", shopify.ListOptions{Limit: 2, PageInfo: pageInfo})
+	got := collectIDs(t, resumed)
+	if len(got) != 3 || got[0] != 3 || got[2] != 5 {
+		t.Errorf("resumed ids = %v, want [3 4 5]", got)
+	}
+}
+
+func TestIteratorFiltersByUpdatedAt(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 6)
+
+	it := newClient(s).Iterate("orders", shopify.ListOptions{
+		Limit:        2,
+		UpdatedAtMin: epoch.Add(4 * time.Hour),
+	})
+	got := collectIDs(t, it)
+	if len(got) != 3 || got[0] != 4 {
+		t.Errorf("ids = %v, want [4 5 6]", got)
+	}
+}
+
+func TestIteratorFetchesLazily(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 6)
+
+	it := newClient(s).Iterate("orders", shopify.ListOptions{Limit: 2})
+	if s.Requests() != 0 {
+		t.Fatalf("Iterate() made %d requests", s.Requests())
+	}
+
+	ctx := context.Background()
+	for idx := 0; idx < 2; idx++ {
+		if !it.Next(ctx) {
+			t.Fatal(it.Err())
+		}
+	}
+	if got := s.Requests(); got != 1 {
+		t.Errorf("Requests() = %d after the first page, want 1", got)
+	}
+
+	if !it.Next(ctx) {
+		t.Fatal(it.Err())
+	}
+	if got := s.Requests(); got != 2 {
+		t.Errorf("Requests() = %d after the second page, want 2", got)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 5)
+
+	ch, err := newClient(s).Paginate(context.Background(), "orders", shopify.ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int64
+	for result := range ch {
+		if err := result.Err(); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, result.Item().ID)
+	}
+	checkIDs(t, ids, 5)
+}
+
+func TestPaginateStopsWhenCancelled(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := newClient(s).Paginate(ctx, "orders", shopify.ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-ch
+	cancel()
+
+	// the feeding goroutine stops and closes the channel
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel was not closed after cancel")
+		}
+	}
+}
@@ -4,10 +4,27 @@ import (
 	"time"
 
 	"github.com/demosdemon/shop/pkg/log"
+	"github.com/demosdemon/shop/pkg/retry"
 )
 
 type Option func(c *Client)
 
+// WithBaseURL overrides the default `https://<store>.myshopify.com` base
+// URL, e.g. to point the client at a local test server.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = &baseURL
+	}
+}
+
+// WithAccessToken authenticates with an Admin API access token, as custom
+// and public apps do, instead of the private app username and password.
+func WithAccessToken(accessToken string) Option {
+	return func(c *Client) {
+		c.accessToken = &accessToken
+	}
+}
+
 func WithAPIVersion(apiVersion string) Option {
 	return func(c *Client) {
 		c.apiVersion = &apiVersion
@@ -32,15 +49,59 @@ func WithRetryCount(retryCount int) Option {
 	}
 }
 
-func WithRetryDelay(retryDelay time.Duration) Option {
+// WithRetryBackoff sets the backoff between retries of a failed request.
+func WithRetryBackoff(retryBackoff retry.Backoff) Option {
+	return func(c *Client) {
+		c.retryBackoff = retryBackoff
+	}
+}
+
+// WithRetryMaxElapsed limits the total time spent on a request and its
+// retries. Zero means no limit.
+func WithRetryMaxElapsed(retryMaxElapsed time.Duration) Option {
+	return func(c *Client) {
+		c.retryMaxElapsed = &retryMaxElapsed
+	}
+}
+
+// WithAttemptTimeout sets a deadline on each attempt of a request, including
+// the wait for the rate limiter and reading the response body. Zero means no
+// deadline beyond the HTTP client's timeout.
+func WithAttemptTimeout(attemptTimeout time.Duration) Option {
+	return func(c *Client) {
+		c.attemptTimeout = &attemptTimeout
+	}
+}
+
+// WithBreaker fails requests fast while breaker is open. It should be shared
+// by every client of the store.
+func WithBreaker(breaker *retry.Breaker) Option {
+	return func(c *Client) {
+		c.breaker = breaker
+	}
+}
+
+// WithRateLimiter replaces the store's shared rate limiter.
+func WithRateLimiter(rateLimiter *RateLimiter) Option {
+	return func(c *Client) {
+		c.rateLimiter = rateLimiter
+	}
+}
+
+// WithRecorder records every request to the recorder's cassette. It wraps
+// the client's current transport, so it should come after any option that
+// replaces the transport.
+func WithRecorder(recorder *Recorder) Option {
 	return func(c *Client) {
-		c.retryDelay = &retryDelay
+		c.Transport = recorder.Transport(c.Transport)
 	}
 }
 
-func WithRetryJitter(retryJitter time.Duration) Option {
+// WithReplayer answers every request from the replayer's cassette instead of
+// the network.
+func WithReplayer(replayer *Replayer) Option {
 	return func(c *Client) {
-		c.retryJitter = &retryJitter
+		c.Transport = replayer.Transport()
 	}
 }
 
@@ -0,0 +1,110 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type OrderService struct {
+	resourceService
+}
+
+// List returns all orders matching options, following every page.
+func (s *OrderService) List(ctx context.Context, options interface{}) ([]Order, error) {
+	var orders []Order
+	err := s.list(ctx, options, &orders)
+	return orders, err
+}
+
+func (s *OrderService) Get(ctx context.Context, id int64, options interface{}) (*Order, error) {
+	order := new(Order)
+	if err := s.get(ctx, id, options, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+type Order struct {
+	ID                int64      `json:"id"`
+	AdminGraphQLAPIID string     `json:"admin_graphql_api_id"`
+	Name              string     `json:"name"`
+	Number            int        `json:"number"`
+	OrderNumber       int        `json:"order_number"`
+	Email             *string    `json:"email"`
+	Phone             *string    `json:"phone"`
+	Note              *string    `json:"note"`
+	Tags              string     `json:"tags"`
+	Test              bool       `json:"test"`
+	Currency          string     `json:"currency"`
+	FinancialStatus   string     `json:"financial_status"`
+	FulfillmentStatus *string    `json:"fulfillment_status"`
+	SubtotalPrice     string     `json:"subtotal_price"`
+	TotalDiscounts    string     `json:"total_discounts"`
+	TotalTax          string     `json:"total_tax"`
+	TotalPrice        string     `json:"total_price"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	ProcessedAt       *time.Time `json:"processed_at"`
+	ClosedAt          *time.Time `json:"closed_at"`
+	CancelledAt       *time.Time `json:"cancelled_at"`
+	CancelReason      *string    `json:"cancel_reason"`
+	Customer          *Customer  `json:"customer"`
+	BillingAddress    *Address   `json:"billing_address"`
+	ShippingAddress   *Address   `json:"shipping_address"`
+	LineItems         []LineItem `json:"line_items"`
+
+	// Raw is the JSON the order was decoded from.
+	Raw json.RawMessage `json:"-"`
+}
+
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type order Order
+	var v order
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*o = Order(v)
+	o.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (o Order) MarshalJSON() ([]byte, error) {
+	type order Order
+	return marshalRaw(o.Raw, order(o))
+}
+
+type LineItem struct {
+	ID                int64   `json:"id"`
+	ProductID         *int64  `json:"product_id"`
+	VariantID         *int64  `json:"variant_id"`
+	Title             string  `json:"title"`
+	VariantTitle      *string `json:"variant_title"`
+	Name              string  `json:"name"`
+	SKU               string  `json:"sku"`
+	Vendor            *string `json:"vendor"`
+	Quantity          int     `json:"quantity"`
+	Price             string  `json:"price"`
+	TotalDiscount     string  `json:"total_discount"`
+	FulfillmentStatus *string `json:"fulfillment_status"`
+	RequiresShipping  bool    `json:"requires_shipping"`
+	Taxable           bool    `json:"taxable"`
+	GiftCard          bool    `json:"gift_card"`
+}
+
+type Address struct {
+	ID           int64   `json:"id,omitempty"`
+	FirstName    *string `json:"first_name"`
+	LastName     *string `json:"last_name"`
+	Company      *string `json:"company"`
+	Address1     *string `json:"address1"`
+	Address2     *string `json:"address2"`
+	City         *string `json:"city"`
+	Province     *string `json:"province"`
+	ProvinceCode *string `json:"province_code"`
+	Country      *string `json:"country"`
+	CountryCode  *string `json:"country_code"`
+	Zip          *string `json:"zip"`
+	Phone        *string `json:"phone"`
+	Default      bool    `json:"default,omitempty"`
+}
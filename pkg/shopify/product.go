@@ -0,0 +1,110 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type ProductService struct {
+	resourceService
+}
+
+// List returns all products matching options, following every page.
+func (s *ProductService) List(ctx context.Context, options interface{}) ([]Product, error) {
+	var products []Product
+	err := s.list(ctx, options, &products)
+	return products, err
+}
+
+func (s *ProductService) Get(ctx context.Context, id int64, options interface{}) (*Product, error) {
+	product := new(Product)
+	if err := s.get(ctx, id, options, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+type Product struct {
+	ID                int64           `json:"id"`
+	AdminGraphQLAPIID string          `json:"admin_graphql_api_id"`
+	Title             string          `json:"title"`
+	BodyHTML          *string         `json:"body_html"`
+	Vendor            string          `json:"vendor"`
+	ProductType       string          `json:"product_type"`
+	Handle            string          `json:"handle"`
+	Tags              string          `json:"tags"`
+	TemplateSuffix    *string         `json:"template_suffix"`
+	PublishedScope    string          `json:"published_scope"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	PublishedAt       *time.Time      `json:"published_at"`
+	Options           []ProductOption `json:"options"`
+	Variants          []Variant       `json:"variants"`
+	Images            []Image         `json:"images"`
+
+	// Raw is the JSON the product was decoded from.
+	Raw json.RawMessage `json:"-"`
+}
+
+func (p *Product) UnmarshalJSON(data []byte) error {
+	type product Product
+	var v product
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*p = Product(v)
+	p.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (p Product) MarshalJSON() ([]byte, error) {
+	type product Product
+	return marshalRaw(p.Raw, product(p))
+}
+
+type ProductOption struct {
+	ID        int64    `json:"id"`
+	ProductID int64    `json:"product_id"`
+	Name      string   `json:"name"`
+	Position  int      `json:"position"`
+	Values    []string `json:"values"`
+}
+
+type Variant struct {
+	ID                int64     `json:"id"`
+	ProductID         int64     `json:"product_id"`
+	Title             string    `json:"title"`
+	SKU               string    `json:"sku"`
+	Barcode           *string   `json:"barcode"`
+	Price             string    `json:"price"`
+	CompareAtPrice    *string   `json:"compare_at_price"`
+	Position          int       `json:"position"`
+	Option1           *string   `json:"option1"`
+	Option2           *string   `json:"option2"`
+	Option3           *string   `json:"option3"`
+	Taxable           bool      `json:"taxable"`
+	Grams             int       `json:"grams"`
+	Weight            float64   `json:"weight"`
+	WeightUnit        string    `json:"weight_unit"`
+	InventoryItemID   int64     `json:"inventory_item_id"`
+	InventoryQuantity int       `json:"inventory_quantity"`
+	InventoryPolicy   string    `json:"inventory_policy"`
+	RequiresShipping  bool      `json:"requires_shipping"`
+	ImageID           *int64    `json:"image_id"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type Image struct {
+	ID         int64     `json:"id"`
+	ProductID  int64     `json:"product_id"`
+	Position   int       `json:"position"`
+	Src        string    `json:"src"`
+	Alt        *string   `json:"alt"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	VariantIDs []int64   `json:"variant_ids"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,144 @@
+package shopify
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBucketSize = 40
+
+	// Shopify leaks 1/20th of a REST bucket per second: 2 requests per
+	// second for the standard 40 request bucket and 4 for Plus stores.
+	bucketLeakDivisor = 20
+)
+
+var sharedRateLimiters = struct {
+	sync.Mutex
+	m map[string]*RateLimiter
+}{m: make(map[string]*RateLimiter)}
+
+// SharedRateLimiter returns the process-wide rate limiter for storeID. It is
+// the default for every Client of that store.
+func SharedRateLimiter(storeID string) *RateLimiter {
+	sharedRateLimiters.Lock()
+	defer sharedRateLimiters.Unlock()
+
+	l, ok := sharedRateLimiters.m[storeID]
+	if !ok {
+		l = NewRateLimiter()
+		sharedRateLimiters.m[storeID] = l
+	}
+	return l
+}
+
+// RateLimiter models a store's REST API leaky bucket. Every request adds one
+// to the bucket, which drains continuously at a rate derived from its size.
+// The observed level and size are corrected from the call limit header after
+// every response. A RateLimiter is safe for concurrent use and may be shared
+// between any number of clients for the same store.
+type RateLimiter struct {
+	mu      sync.Mutex
+	size    int
+	level   float64
+	updated time.Time
+	until   time.Time
+	info    RateLimitInfo
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{size: DefaultBucketSize}
+}
+
+// Wait blocks until the bucket has room for another request, leaving some
+// headroom for requests made outside of this process, and then reserves it.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		wait := l.reserve(time.Now())
+		if wait <= 0 {
+			return nil
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Update corrects the model with the rate limit information from a response.
+func (l *RateLimiter) Update(info RateLimitInfo) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.leak(now)
+
+	if info.BucketSize > 0 {
+		l.size = info.BucketSize
+		l.info = info
+	}
+
+	// requests still in flight have been reserved but may not have been
+	// counted by Shopify yet, so only ever raise the level
+	if level := float64(info.RequestCount); level > l.level {
+		l.level = level
+	}
+
+	if info.RetryAfter > 0 {
+		if until := now.Add(info.RetryAfter); until.After(l.until) {
+			l.until = until
+		}
+	}
+}
+
+// Info returns the rate limit information from the latest response that
+// included the call limit header.
+func (l *RateLimiter) Info() RateLimitInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.info
+}
+
+func (l *RateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.until) {
+		return l.until.Sub(now)
+	}
+
+	l.leak(now)
+	if over := l.level + 1 - l.threshold(); over > 0 {
+		return time.Duration(over / l.leakRate() * float64(time.Second))
+	}
+
+	l.level++
+	return 0
+}
+
+func (l *RateLimiter) leak(now time.Time) {
+	if !l.updated.IsZero() {
+		l.level -= now.Sub(l.updated).Seconds() * l.leakRate()
+		if l.level < 0 {
+			l.level = 0
+		}
+	}
+	l.updated = now
+}
+
+func (l *RateLimiter) leakRate() float64 {
+	return float64(l.size) / bucketLeakDivisor
+}
+
+func (l *RateLimiter) threshold() float64 {
+	headroom := l.size / 10
+	if headroom < 1 {
+		headroom = 1
+	}
+	return float64(l.size - headroom)
+}
@@ -0,0 +1,123 @@
+package shopify
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fill reserves every request the limiter allows at now.
+func fill(t *testing.T, l *RateLimiter, now time.Time) int {
+	t.Helper()
+	n := 0
+	for l.reserve(now) == 0 {
+		if n++; n > 1000 {
+			t.Fatal("the bucket never filled")
+		}
+	}
+	return n
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	l := NewRateLimiter()
+
+	// a tenth of the bucket is left for requests made elsewhere
+	if n := fill(t, l, epoch); n != 36 {
+		t.Errorf("reserved %d requests, want 36", n)
+	}
+
+	// the bucket leaks 2 requests per second
+	if wait := l.reserve(epoch); wait != 500*time.Millisecond {
+		t.Errorf("reserve() = %s once full, want 500ms", wait)
+	}
+}
+
+func TestRateLimiterLeak(t *testing.T) {
+	l := NewRateLimiter()
+	fill(t, l, epoch)
+
+	if n := fill(t, l, epoch.Add(time.Second)); n != 2 {
+		t.Errorf("reserved %d requests a second later, want 2", n)
+	}
+
+	// the level never drops below empty
+	if n := fill(t, l, epoch.Add(time.Hour)); n != 36 {
+		t.Errorf("reserved %d requests an hour later, want 36", n)
+	}
+}
+
+func TestRateLimiterUpdate(t *testing.T) {
+	l := NewRateLimiter()
+
+	// a Plus store's bucket is larger and leaks faster
+	info := RateLimitInfo{RequestCount: 70, BucketSize: 80}
+	l.Update(info)
+	if got := l.Info(); got != info {
+		t.Errorf("Info() = %+v, want %+v", got, info)
+	}
+	if n := fill(t, l, time.Now()); n != 2 {
+		t.Errorf("reserved %d requests, want 2", n)
+	}
+	if wait := l.reserve(time.Now()); wait <= 0 || wait > 250*time.Millisecond {
+		t.Errorf("reserve() = %s once full, want at most 250ms", wait)
+	}
+
+	// requests in flight are not forgotten by an older count
+	l.Update(RateLimitInfo{RequestCount: 1, BucketSize: 80})
+	if wait := l.reserve(time.Now()); wait <= 0 {
+		t.Error("a lower count emptied the bucket")
+	}
+
+	// Retry-After holds every request back
+	l.Update(RateLimitInfo{RetryAfter: time.Minute})
+	if wait := l.reserve(time.Now()); wait < 59*time.Second {
+		t.Errorf("reserve() = %s after Retry-After, want about a minute", wait)
+	}
+	if got := l.Info(); got.BucketSize != 80 {
+		t.Errorf("Info() = %+v after a response without a call limit", got)
+	}
+}
+
+func TestRateLimitInfoFromHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  http.Header
+		want    RateLimitInfo
+		wantErr bool
+	}{
+		{name: "none", header: http.Header{}},
+		{
+			name:   "call limit",
+			header: http.Header{hAPICallLimit: {"39/80"}},
+			want:   RateLimitInfo{RequestCount: 39, BucketSize: 80},
+		},
+		{
+			name:   "retry after",
+			header: http.Header{hAPICallLimit: {"40/40"}, "Retry-After": {"2.0"}},
+			want:   RateLimitInfo{RequestCount: 40, BucketSize: 40, RetryAfter: 2 * time.Second},
+		},
+		{name: "bad count", header: http.Header{hAPICallLimit: {"many/40"}}, wantErr: true},
+		{name: "bad size", header: http.Header{hAPICallLimit: {"1/lots"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var info RateLimitInfo
+			err := info.update(&http.Response{Header: tt.header})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("update() = %+v, want error", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info != tt.want {
+				t.Errorf("update() = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package shopify
+
+import (
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/demosdemon/shop/pkg/data"
+)
+
+// Resource describes a REST resource that can be synced.
+type Resource struct {
+	// Name identifies the resource on the command line and names its
+	// output file.
+	Name string
+
+	// Path is the resource path relative to the versioned admin API,
+	// without the `.json` suffix. Child resources have a `{parent_id}`
+	// placeholder for the id of their parent record.
+	Path string
+
+	// Parent is the name of the resource a child resource is nested under.
+	Parent string
+
+	// Key is the JSON key holding the records in a list response.
+	Key string
+
+	// Countable is true if `<Path>/count.json` exists.
+	Countable bool
+
+	// Timestamps are the record's created and updated timestamp fields.
+	Timestamps data.Timestamps
+
+	// Params are added to the first request of every pagination, unless
+	// the caller already set them.
+	Params url.Values
+
+	// Since and Until bound the API versions the resource is available in.
+	// Since is inclusive, Until is exclusive and either may be empty.
+	Since string
+	Until string
+
+	// BulkQuery is the GraphQL bulk operation query that exports the
+	// resource, if it has one.
+	BulkQuery string
+
+	// EventSubject is the event subject type of the resource's records,
+	// used to find deleted records in the events resource. It is only set
+	// when the subject type identifies the resource on its own.
+	EventSubject string
+
+	// Reconcile is true for resources whose deletions the events resource
+	// does not report. Their deleted records are found by comparing the ids
+	// in the output file with the ids Shopify still lists.
+	Reconcile bool
+
+	// Scopes are the access scopes needed to read the resource.
+	Scopes []string
+}
+
+// ForParent returns a copy of a child resource with its path bound to the
+// parent record with the given id.
+func (r *Resource) ForParent(id int64) *Resource {
+	c := *r
+	c.Path = strings.Replace(r.Path, parentIDPlaceholder, strconv.FormatInt(id, 10), 1)
+	return &c
+}
+
+// AvailableIn reports whether the resource exists in the given API version.
+func (r *Resource) AvailableIn(version string) bool {
+	if r.Since != "" && version < r.Since {
+		return false
+	}
+	if r.Until != "" && version >= r.Until {
+		return false
+	}
+	return true
+}
+
+const parentIDPlaceholder = "{parent_id}"
+
+// CursorPaginationVersion is the first API version that pages with page_info
+// cursors, which is how every resource is synced.
+const CursorPaginationVersion = "2019-07"
+
+// DefaultResources are synced when no resources are specified.
+var DefaultResources = []string{
+	"orders",
+	"products",
+	"customers",
+}
+
+var resources = []*Resource{
+	{
+		Name:       "orders",
+		Path:       "orders",
+		Key:        "orders",
+		Countable:  true,
+		Timestamps: data.DefaultTimestamps,
+		Since:      CursorPaginationVersion,
+		// only open orders are returned by default
+		Params:       url.Values{"status": {"any"}},
+		BulkQuery:    ordersBulkQuery,
+		EventSubject: "Order",
+		// without read_all_orders only the last 60 days of orders are
+		// visible
+		Scopes: []string{"read_orders", "read_all_orders"},
+	},
+	{
+		Name:         "products",
+		Path:         "products",
+		Key:          "products",
+		Countable:    true,
+		Timestamps:   data.DefaultTimestamps,
+		Since:        CursorPaginationVersion,
+		BulkQuery:    productsBulkQuery,
+		EventSubject: "Product",
+		Scopes:       []string{"read_products"},
+	},
+	{
+		Name:       "customers",
+		Path:       "customers",
+		Key:        "customers",
+		Countable:  true,
+		Timestamps: data.DefaultTimestamps,
+		Since:      CursorPaginationVersion,
+		BulkQuery:  customersBulkQuery,
+		// customers are not an events subject type
+		Reconcile: true,
+		Scopes:    []string{"read_customers"},
+	},
+	{
+		Name:       "order_transactions",
+		Path:       "orders/{parent_id}/transactions",
+		Parent:     "orders",
+		Key:        "transactions",
+		Timestamps: data.Timestamps{CreatedAt: "created_at"},
+		Since:      CursorPaginationVersion,
+		Scopes:     []string{"read_orders"},
+	},
+	{
+		Name:       "order_refunds",
+		Path:       "orders/{parent_id}/refunds",
+		Parent:     "orders",
+		Key:        "refunds",
+		Timestamps: data.Timestamps{CreatedAt: "created_at"},
+		Since:      CursorPaginationVersion,
+		Scopes:     []string{"read_orders"},
+	},
+	{
+		Name:       "order_fulfillments",
+		Path:       "orders/{parent_id}/fulfillments",
+		Parent:     "orders",
+		Key:        "fulfillments",
+		Countable:  true,
+		Timestamps: data.DefaultTimestamps,
+		Since:      CursorPaginationVersion,
+		Scopes:     []string{"read_orders"},
+	},
+	{
+		Name:       "product_metafields",
+		Path:       "products/{parent_id}/metafields",
+		Parent:     "products",
+		Key:        "metafields",
+		Countable:  true,
+		Timestamps: data.DefaultTimestamps,
+		Since:      CursorPaginationVersion,
+		Scopes:     []string{"read_products"},
+	},
+	{
+		Name:       "custom_collections",
+		Path:       "custom_collections",
+		Key:        "custom_collections",
+		Countable:  true,
+		Timestamps: data.DefaultTimestamps,
+		Since:      CursorPaginationVersion,
+		// both kinds of collection share the Collection subject type
+		Reconcile: true,
+		Scopes:    []string{"read_products"},
+	},
+	{
+		Name:       "smart_collections",
+		Path:       "smart_collections",
+		Key:        "smart_collections",
+		Countable:  true,
+		Timestamps: data.DefaultTimestamps,
+		Since:      CursorPaginationVersion,
+		// both kinds of collection share the Collection subject type
+		Reconcile: true,
+		Scopes:    []string{"read_products"},
+	},
+	{
+		Name:       "events",
+		Path:       "events",
+		Key:        "events",
+		Countable:  true,
+		Timestamps: data.Timestamps{CreatedAt: "created_at"},
+		Since:      CursorPaginationVersion,
+		// events need no scope of their own; only the events of subjects
+		// the other scopes can read are returned
+	},
+}
+
+// Resources returns every registered resource.
+func Resources() []*Resource {
+	rv := make([]*Resource, len(resources))
+	copy(rv, resources)
+	return rv
+}
+
+// Children returns the registered child resources of the named resource.
+func Children(parent string) []*Resource {
+	var rv []*Resource
+	for _, r := range resources {
+		if r.Parent == parent {
+			rv = append(rv, r)
+		}
+	}
+	return rv
+}
+
+// LookupResource returns the registered resource with the given name.
+func LookupResource(name string) (*Resource, bool) {
+	for _, r := range resources {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// ResourceFor returns the registered resource with the given name, or a
+// resource that assumes the conventional REST layout for unknown names.
+func ResourceFor(name string) *Resource {
+	if r, ok := LookupResource(name); ok {
+		return r
+	}
+
+	name = strings.Trim(name, "/")
+	return &Resource{
+		Name:       name,
+		Path:       name,
+		Key:        path.Base(name),
+		Countable:  true,
+		Timestamps: data.DefaultTimestamps,
+		Since:      CursorPaginationVersion,
+	}
+}
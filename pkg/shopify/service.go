@@ -0,0 +1,109 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/demosdemon/shop/pkg/data"
+)
+
+// resourceService implements the REST calls shared by the typed services.
+type resourceService struct {
+	client   *Client
+	element  string
+	singular string
+}
+
+func (s *resourceService) Count(ctx context.Context, options interface{}) (int, error) {
+	return s.client.Count(ctx, s.client.Path(s.element), options)
+}
+
+// list decodes every record matching options into v, a pointer to a slice,
+// following page_info cursors until the last page.
+func (s *resourceService) list(ctx context.Context, options interface{}, v interface{}) error {
+	// the records are decoded from their JSON, so they need no timestamps
+	// and options may select any fields; the sync's default parameters do
+	// not apply either
+	resource := *ResourceFor(s.element)
+	resource.Timestamps = data.Timestamps{}
+	resource.Params = nil
+
+	it := s.client.IterateResource(&resource, options)
+	defer func() { _ = it.Close() }()
+
+	records := []json.RawMessage{}
+	for it.Next(ctx) {
+		records = append(records, it.Item().Raw)
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	// re-encoding raw messages cannot fail
+	buf, _ := json.Marshal(records)
+	return json.Unmarshal(buf, v)
+}
+
+func (s *resourceService) get(ctx context.Context, id int64, options interface{}, v interface{}) error {
+	relPath := fmt.Sprintf("%s/%d.json", s.element, id)
+	res, err := s.client.Get(ctx, s.client.Path(relPath), options)
+	if err != nil {
+		return err
+	}
+
+	return s.decode(res, s.singular, v)
+}
+
+func (s *resourceService) create(ctx context.Context, record interface{}, v interface{}) error {
+	res, err := s.client.Post(ctx, s.client.Path(s.element)+".json", map[string]interface{}{s.singular: record})
+	if err != nil {
+		return err
+	}
+
+	return s.decode(res, s.singular, v)
+}
+
+func (s *resourceService) update(ctx context.Context, id int64, record interface{}, v interface{}) error {
+	relPath := fmt.Sprintf("%s/%d.json", s.element, id)
+	res, err := s.client.Put(ctx, s.client.Path(relPath), map[string]interface{}{s.singular: record})
+	if err != nil {
+		return err
+	}
+
+	return s.decode(res, s.singular, v)
+}
+
+func (s *resourceService) delete(ctx context.Context, id int64) error {
+	relPath := fmt.Sprintf("%s/%d.json", s.element, id)
+	res, err := s.client.Delete(ctx, s.client.Path(relPath))
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+func (s *resourceService) decode(res *http.Response, key string, v interface{}) error {
+	var resource map[string]json.RawMessage
+	if err := s.client.Deserialize(res, &resource); err != nil {
+		return err
+	}
+
+	value, ok := resource[key]
+	if !ok {
+		return NewResponseDecodingError(res, fmt.Errorf("response does not have a value for key: %s", key), nil)
+	}
+
+	return NewResponseDecodingError(res, json.Unmarshal(value, v), value)
+}
+
+// marshalRaw returns the original JSON of a record if it has one, so that
+// re-encoding a decoded record is lossless.
+func marshalRaw(raw json.RawMessage, v interface{}) ([]byte, error) {
+	if len(raw) > 0 {
+		return raw, nil
+	}
+	return json.Marshal(v)
+}
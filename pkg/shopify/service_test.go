@@ -0,0 +1,56 @@
+package shopify_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+func TestOrdersListFollowsEveryPage(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 5)
+
+	orders, err := newClient(s).Orders.List(context.Background(), shopify.ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 5 {
+		t.Fatalf("len(orders) = %d, want 5", len(orders))
+	}
+	for idx, order := range orders {
+		if order.ID != int64(idx+1) || order.Email == nil || *order.Email != fmt.Sprintf("%d@example.com", idx+1) {
+			t.Errorf("orders[%d] = %d, %v", idx, order.ID, order.Email)
+		}
+	}
+}
+
+func TestOrdersGetAndCount(t *testing.T) {
+	s := shopifytest.NewServer()
+	defer s.Close()
+	seedOrders(t, s, 3)
+	client := newClient(s)
+
+	order, err := client.Orders.Get(context.Background(), 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != 2 || order.Email == nil || *order.Email != "2@example.com" {
+		t.Errorf("order = %d, %v", order.ID, order.Email)
+	}
+	if len(order.Raw) == 0 {
+		t.Error("Raw is empty")
+	}
+
+	count, err := client.Orders.Count(context.Background(), shopify.CountOptions{UpdatedAtMin: epoch.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Count() = %d, want 2", count)
+	}
+}
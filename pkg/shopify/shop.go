@@ -0,0 +1,80 @@
+package shopify
+
+import (
+	"context"
+	"strings"
+)
+
+const accessScopesPath = "admin/oauth/access_scopes.json"
+
+type Shop struct {
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	Domain          string `json:"domain"`
+	MyshopifyDomain string `json:"myshopify_domain"`
+	PlanName        string `json:"plan_name"`
+	PlanDisplayName string `json:"plan_display_name"`
+	IANATimezone    string `json:"iana_timezone"`
+	Timezone        string `json:"timezone"`
+	Currency        string `json:"currency"`
+	CountryCode     string `json:"country_code"`
+}
+
+// Shop returns the store's shop settings.
+func (c *Client) Shop(ctx context.Context) (*Shop, error) {
+	res, err := c.Get(ctx, c.Path("shop.json"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resource struct {
+		Shop *Shop `json:"shop"`
+	}
+	if err := c.Deserialize(res, &resource); err != nil {
+		return nil, err
+	}
+
+	return resource.Shop, nil
+}
+
+type AccessScope struct {
+	Handle string `json:"handle"`
+}
+
+// AccessScopes returns the handles of the scopes granted to the client's
+// credentials. The endpoint is not versioned.
+func (c *Client) AccessScopes(ctx context.Context) ([]string, error) {
+	res, err := c.Get(ctx, accessScopesPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resource struct {
+		AccessScopes []AccessScope `json:"access_scopes"`
+	}
+	if err := c.Deserialize(res, &resource); err != nil {
+		return nil, err
+	}
+
+	handles := make([]string, len(resource.AccessScopes))
+	for idx, scope := range resource.AccessScopes {
+		handles[idx] = scope.Handle
+	}
+	return handles, nil
+}
+
+// HasScope reports whether scope is among the granted scopes. A write scope
+// implies the matching read scope.
+func HasScope(granted []string, scope string) bool {
+	implied := ""
+	if strings.HasPrefix(scope, "read_") {
+		implied = "write_" + strings.TrimPrefix(scope, "read_")
+	}
+
+	for _, handle := range granted {
+		if handle == scope || handle == implied {
+			return true
+		}
+	}
+	return false
+}
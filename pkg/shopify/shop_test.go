@@ -0,0 +1,33 @@
+package shopify_test
+
+import (
+	"testing"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+func TestHasScope(t *testing.T) {
+	granted := []string{"read_orders", "write_products"}
+
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{"read_orders", true},
+		{"write_orders", false},
+		{"write_products", true},
+		// a write scope implies the matching read scope, not the other way
+		{"read_products", true},
+		{"read_customers", false},
+		{"write_customers", false},
+	}
+
+	for _, tt := range tests {
+		if got := shopify.HasScope(granted, tt.scope); got != tt.want {
+			t.Errorf("HasScope(%v, %q) = %t, want %t", granted, tt.scope, got, tt.want)
+		}
+	}
+	if shopify.HasScope(nil, "read_orders") {
+		t.Error("HasScope(nil, read_orders) = true")
+	}
+}
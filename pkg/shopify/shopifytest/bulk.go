@@ -0,0 +1,219 @@
+package shopifytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+const (
+	bulkOperationGID = "gid://shopify/BulkOperation/"
+
+	// throttledCost is the query cost reported by throttled responses.
+	throttledCost = 10
+)
+
+var bulkElementRegexp = regexp.MustCompile(`^\s*\{\s*(\w+)`)
+
+type bulkOperation struct {
+	shopify.BulkOperation
+	element string
+	polls   int
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// serveGraphQL understands just enough GraphQL to drive bulk operations.
+func (s *Server) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query, _ := req.Variables["query"].(string)
+	id, _ := req.Variables["id"].(string)
+
+	switch {
+	case strings.Contains(req.Query, "bulkOperationRunQuery"):
+		s.runBulkQuery(w, query)
+	case strings.Contains(req.Query, "bulkOperationCancel"):
+		s.cancelBulkOperation(w, id)
+	case strings.Contains(req.Query, "node("):
+		s.bulkOperationNode(w, id)
+	default:
+		writeGraphQLErrors(w, "unsupported query")
+	}
+}
+
+func (s *Server) runBulkQuery(w http.ResponseWriter, query string) {
+	m := bulkElementRegexp.FindStringSubmatch(query)
+	if m == nil {
+		writeUserErrors(w, "bulkOperationRunQuery", []string{"query"}, "Invalid bulk query")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, op := range s.bulkOps {
+		if !op.Done() {
+			writeUserErrors(w, "bulkOperationRunQuery", nil, "A bulk query operation for this app and shop is already in progress: "+op.ID+".")
+			return
+		}
+	}
+
+	op := &bulkOperation{
+		BulkOperation: shopify.BulkOperation{
+			ID:          bulkOperationGID + strconv.Itoa(len(s.bulkOps)+1),
+			Status:      shopify.BulkOperationCreated,
+			ObjectCount: "0",
+			CreatedAt:   time.Now().UTC(),
+		},
+		element: m[1],
+	}
+	s.bulkOps = append(s.bulkOps, op)
+
+	writeGraphQLData(w, map[string]interface{}{
+		"bulkOperationRunQuery": map[string]interface{}{
+			"bulkOperation": op.BulkOperation,
+			"userErrors":    []interface{}{},
+		},
+	})
+}
+
+func (s *Server) cancelBulkOperation(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op := s.findBulkOperation(id)
+	if op == nil {
+		writeUserErrors(w, "bulkOperationCancel", []string{"id"}, "Bulk operation does not exist")
+		return
+	}
+
+	if !op.Done() {
+		op.Status = shopify.BulkOperationCanceled
+	}
+
+	writeGraphQLData(w, map[string]interface{}{
+		"bulkOperationCancel": map[string]interface{}{
+			"bulkOperation": op.BulkOperation,
+			"userErrors":    []interface{}{},
+		},
+	})
+}
+
+func (s *Server) bulkOperationNode(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op := s.findBulkOperation(id)
+	if op == nil {
+		writeGraphQLData(w, map[string]interface{}{"node": nil})
+		return
+	}
+
+	if !op.Done() {
+		op.polls++
+		op.Status = shopify.BulkOperationRunning
+		if op.polls > s.BulkPolls {
+			s.completeBulkOperation(op)
+		}
+	}
+
+	writeGraphQLData(w, map[string]interface{}{"node": op.BulkOperation})
+}
+
+func (s *Server) completeBulkOperation(op *bulkOperation) {
+	now := time.Now().UTC()
+	count := len(s.records[op.element])
+
+	op.Status = shopify.BulkOperationCompleted
+	op.CompletedAt = &now
+	op.ObjectCount = strconv.Itoa(count)
+	if count > 0 {
+		u := s.URL + bulkPrefix + strings.TrimPrefix(op.ID, bulkOperationGID) + ".jsonl"
+		op.URL = &u
+	}
+}
+
+func (s *Server) findBulkOperation(id string) *bulkOperation {
+	for _, op := range s.bulkOps {
+		if op.ID == id {
+			return op
+		}
+	}
+	return nil
+}
+
+func (s *Server) serveBulkResult(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, bulkPrefix), ".jsonl")
+
+	s.mu.Lock()
+	op := s.findBulkOperation(bulkOperationGID + id)
+	s.mu.Unlock()
+
+	if op == nil || op.Status != shopify.BulkOperationCompleted {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jsonl")
+	for _, record := range s.snapshot(op.element) {
+		_, _ = fmt.Fprintf(w, "%s\n", record)
+	}
+}
+
+func writeGraphQLData(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func writeGraphQLErrors(w http.ResponseWriter, messages ...string) {
+	errs := make([]shopify.GraphQLError, len(messages))
+	for idx, message := range messages {
+		errs[idx].Message = message
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"errors": errs})
+}
+
+// writeThrottled reports a THROTTLED query whose cost restores after wait.
+// Without a wait, the bucket does not restore and clients fall back to their
+// own delay.
+func writeThrottled(w http.ResponseWriter, wait time.Duration) {
+	status := shopify.ThrottleStatus{MaximumAvailable: 1000}
+	if wait > 0 {
+		status.RestoreRate = throttledCost / wait.Seconds()
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"errors": []shopify.GraphQLError{{
+			Message:    "Throttled",
+			Extensions: map[string]interface{}{"code": "THROTTLED"},
+		}},
+		"extensions": map[string]interface{}{
+			"cost": shopify.QueryCost{
+				RequestedQueryCost: throttledCost,
+				ThrottleStatus:     status,
+			},
+		},
+	})
+}
+
+func writeUserErrors(w http.ResponseWriter, mutation string, field []string, message string) {
+	writeGraphQLData(w, map[string]interface{}{
+		mutation: map[string]interface{}{
+			"bulkOperation": nil,
+			"userErrors":    []shopify.UserError{{Field: field, Message: message}},
+		},
+	})
+}
@@ -0,0 +1,160 @@
+package shopifytest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultBucketSize and DefaultLeakRate match Shopify's REST limits for
+	// standard plans.
+	DefaultBucketSize = 40
+	DefaultLeakRate   = 2.0
+
+	hAPICallLimit = "X-Shopify-Shop-Api-Call-Limit"
+	hRetryAfter   = "Retry-After"
+)
+
+// Fault replaces or slows down the responses to matching requests.
+type Fault struct {
+	// Path selects the requests the fault applies to by prefix, relative to
+	// the versioned admin API, e.g. "orders" or "graphql.json". An empty
+	// path matches every request.
+	Path string
+
+	// Status, if set, is returned instead of the real response.
+	Status int
+
+	// Throttled, if set, answers with a GraphQL THROTTLED error instead of
+	// the real response.
+	Throttled bool
+
+	// RetryAfter is sent in the Retry-After header of the injected response.
+	// For a throttled response, it is how long the reported query cost takes
+	// to restore.
+	RetryAfter time.Duration
+
+	// Delay is waited before responding.
+	Delay time.Duration
+
+	// Count is the number of requests the fault applies to. Zero applies it
+	// to every request until the faults are cleared.
+	Count int
+}
+
+// Inject adds a fault. Faults are matched in the order they were injected and
+// at most one applies to a request.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes every injected fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests returns the number of admin API requests served so far.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) fault(p string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	for idx, f := range s.faults {
+		if !strings.HasPrefix(p, f.Path) {
+			continue
+		}
+
+		rv := *f
+		if f.Count > 0 {
+			if f.Count--; f.Count == 0 {
+				s.faults = append(s.faults[:idx:idx], s.faults[idx+1:]...)
+			}
+		}
+		return &rv
+	}
+
+	return nil
+}
+
+// applyFault waits out the fault's delay and writes its response. It returns
+// true if the request has been answered.
+func applyFault(w http.ResponseWriter, r *http.Request, f *Fault) bool {
+	if f.Delay > 0 {
+		t := time.NewTimer(f.Delay)
+		select {
+		case <-r.Context().Done():
+			t.Stop()
+			return true
+		case <-t.C:
+		}
+	}
+
+	if f.Throttled {
+		writeThrottled(w, f.RetryAfter)
+		return true
+	}
+
+	if f.Status == 0 {
+		return false
+	}
+
+	if f.RetryAfter > 0 {
+		w.Header().Set(hRetryAfter, formatSeconds(f.RetryAfter))
+	}
+	writeError(w, f.Status, http.StatusText(f.Status))
+	return true
+}
+
+// take leaks the call limit bucket and adds a request to it. It returns the
+// bucket's level and false if the bucket is full.
+func (s *Server) take() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if !s.leaked.IsZero() {
+		s.level -= now.Sub(s.leaked).Seconds() * s.LeakRate
+		if s.level < 0 {
+			s.level = 0
+		}
+	}
+	s.leaked = now
+
+	if s.level+1 > float64(s.BucketSize) {
+		return s.BucketSize, false
+	}
+
+	s.level++
+	return int(s.level + 0.5), true
+}
+
+// limit sets the call limit headers and rejects the request with 429 if the
+// bucket is full. It returns true if the request has been answered.
+func (s *Server) limit(w http.ResponseWriter) bool {
+	level, ok := s.take()
+	w.Header().Set(hAPICallLimit, fmt.Sprintf("%d/%d", level, s.BucketSize))
+	if ok {
+		return false
+	}
+
+	w.Header().Set(hRetryAfter, formatSeconds(time.Duration(float64(time.Second)/s.LeakRate)))
+	writeError(w, http.StatusTooManyRequests, "Exceeded 2 calls per second for api client. Reduce request rates to resume uninterrupted service.")
+	return true
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 1, 64)
+}
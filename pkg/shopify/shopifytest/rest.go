@@ -0,0 +1,227 @@
+package shopifytest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 250
+)
+
+// cursor is the decoded form of a page_info parameter. Like Shopify's, it
+// carries the filters of the first request so later pages only need a limit.
+type cursor struct {
+	Query  string `json:"query"`
+	LastID int64  `json:"last_id"`
+}
+
+func (c cursor) encode() string {
+	buf, _ := json.Marshal(c)
+	return base64.RawStdEncoding.EncodeToString(buf)
+}
+
+func decodeCursor(s string) (c cursor, err error) {
+	buf, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(buf, &c)
+	return c, err
+}
+
+// serveREST serves the records seeded under element, which is the request
+// path without the API prefix, version and `.json` suffix. Records are listed
+// in id order and can be filtered with ids, since_id and the created_at and
+// updated_at bounds; other parameters are ignored.
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request, element string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	if strings.HasSuffix(element, "/count") {
+		s.serveCount(w, r, strings.TrimSuffix(element, "/count"))
+		return
+	}
+
+	if id, err := strconv.ParseInt(path.Base(element), 10, 64); err == nil {
+		s.serveRecord(w, path.Dir(element), id)
+		return
+	}
+
+	s.serveList(w, r, element)
+}
+
+func (s *Server) serveCount(w http.ResponseWriter, r *http.Request, element string) {
+	records, err := filterRecords(s.snapshot(element), r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{"count": len(records)})
+}
+
+func (s *Server) serveRecord(w http.ResponseWriter, element string, id int64) {
+	for _, record := range s.snapshot(element) {
+		if gjson.GetBytes(record, "id").Int() == id {
+			key := strings.TrimSuffix(path.Base(element), "s")
+			writeJSON(w, http.StatusOK, map[string]json.RawMessage{key: record})
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) serveList(w http.ResponseWriter, r *http.Request, element string) {
+	q := r.URL.Query()
+
+	limit := defaultLimit
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxLimit {
+			writeFieldError(w, "limit", "Invalid value.")
+			return
+		}
+	}
+
+	var c cursor
+	if pageInfo := q.Get("page_info"); pageInfo != "" {
+		for k := range q {
+			if k != "page_info" && k != "limit" && k != "fields" {
+				writeFieldError(w, "page_info", "Invalid value. "+k+" cannot be passed with page_info.")
+				return
+			}
+		}
+
+		var err error
+		if c, err = decodeCursor(pageInfo); err != nil {
+			writeFieldError(w, "page_info", "Invalid value.")
+			return
+		}
+	} else {
+		q.Del("limit")
+		q.Del("fields")
+		c.Query = q.Encode()
+	}
+
+	filters, err := url.ParseQuery(c.Query)
+	if err != nil {
+		writeFieldError(w, "page_info", "Invalid value.")
+		return
+	}
+
+	records, err := filterRecords(s.snapshot(element), filters)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	idx := sort.Search(len(records), func(idx int) bool {
+		return gjson.GetBytes(records[idx], "id").Int() > c.LastID
+	})
+	records = records[idx:]
+
+	if len(records) > limit {
+		c.LastID = gjson.GetBytes(records[limit-1], "id").Int()
+		next := url.Values{
+			"limit":     {strconv.Itoa(limit)},
+			"page_info": {c.encode()},
+		}
+		u := s.URL + r.URL.Path + "?" + next.Encode()
+		w.Header().Set("Link", "<"+u+">; rel=\"next\"")
+		records = records[:limit]
+	}
+
+	if records == nil {
+		records = []json.RawMessage{}
+	}
+	writeJSON(w, http.StatusOK, map[string][]json.RawMessage{path.Base(element): records})
+}
+
+// filterRecords returns the records matching the query, sorted by id.
+func filterRecords(records []json.RawMessage, q url.Values) ([]json.RawMessage, error) {
+	type bound struct {
+		field string
+		after bool
+		t     time.Time
+	}
+
+	var bounds []bound
+	for _, param := range []string{"created_at_min", "created_at_max", "updated_at_min", "updated_at_max"} {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, err
+		}
+
+		bounds = append(bounds, bound{
+			field: strings.TrimSuffix(strings.TrimSuffix(param, "_min"), "_max"),
+			after: strings.HasSuffix(param, "_min"),
+			t:     t,
+		})
+	}
+
+	var sinceID int64
+	if v := q.Get("since_id"); v != "" {
+		var err error
+		if sinceID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	var ids map[int64]bool
+	if v := q.Get("ids"); v != "" {
+		ids = make(map[int64]bool)
+		for _, s := range strings.Split(v, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			ids[id] = true
+		}
+	}
+
+	var rv []json.RawMessage
+Records:
+	for _, record := range records {
+		id := gjson.GetBytes(record, "id").Int()
+		if id <= sinceID || (ids != nil && !ids[id]) {
+			continue
+		}
+
+		for _, b := range bounds {
+			t, err := time.Parse(time.RFC3339, gjson.GetBytes(record, b.field).String())
+			if err != nil || (b.after && t.Before(b.t)) || (!b.after && t.After(b.t)) {
+				continue Records
+			}
+		}
+
+		rv = append(rv, record)
+	}
+
+	sort.SliceStable(rv, func(i, j int) bool {
+		return gjson.GetBytes(rv[i], "id").Int() < gjson.GetBytes(rv[j], "id").Int()
+	})
+	return rv, nil
+}
+
+func writeFieldError(w http.ResponseWriter, field, message string) {
+	writeJSON(w, http.StatusBadRequest, map[string]map[string]string{"errors": {field: message}})
+}
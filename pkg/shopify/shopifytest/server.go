@@ -0,0 +1,147 @@
+// Package shopifytest provides a local fake of the Shopify Admin API for
+// exercising shopify.Client without talking to Shopify. It serves seeded
+// records over REST with cursor pagination and call limits, runs GraphQL bulk
+// operations over the same records, and can inject failures and latency.
+package shopifytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+// Credentials accepted by the server, either as basic auth or as an access
+// token.
+const (
+	Username    = "username"
+	Password    = "password"
+	AccessToken = "access-token"
+
+	adminPrefix = "/admin/api/"
+	bulkPrefix  = "/bulk/"
+)
+
+type Server struct {
+	*httptest.Server
+
+	// BulkPolls is the number of times a bulk operation reports RUNNING
+	// before it completes.
+	BulkPolls int
+
+	// BucketSize and LeakRate configure the REST call limit: the bucket
+	// holds BucketSize requests and drains LeakRate requests per second.
+	// shopify.RateLimiter assumes a twentieth of the bucket drains per
+	// second, as it does on Shopify.
+	BucketSize int
+	LeakRate   float64
+
+	mu       sync.Mutex
+	records  map[string][]json.RawMessage
+	bulkOps  []*bulkOperation
+	faults   []*Fault
+	requests int
+	level    float64
+	leaked   time.Time
+}
+
+// NewServer starts a fake Admin API server. The caller must Close it.
+func NewServer() *Server {
+	s := &Server{
+		BulkPolls:  1,
+		BucketSize: DefaultBucketSize,
+		LeakRate:   DefaultLeakRate,
+		records:    make(map[string][]json.RawMessage),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(adminPrefix, s.serveAdmin)
+	mux.HandleFunc(bulkPrefix, s.serveBulkResult)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Client returns a client for storeID pointed at the server. Additional
+// options are applied after the base URL.
+func (s *Server) Client(storeID string, options ...shopify.Option) *shopify.Client {
+	options = append([]shopify.Option{shopify.WithBaseURL(s.URL)}, options...)
+	return shopify.New(storeID, Username, Password, options...)
+}
+
+// Seed appends records to element. Each record is marshaled to JSON and
+// should have an integer id. Element is the resource path, so records of
+// nested resources are seeded under their parent, e.g.
+// "orders/1/transactions".
+func (s *Server) Seed(element string, records ...interface{}) error {
+	raw := make([]json.RawMessage, len(records))
+	for idx, record := range records {
+		buf, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		raw[idx] = buf
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[element] = append(s.records[element], raw...)
+	return nil
+}
+
+func (s *Server) snapshot(element string) []json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := s.records[element]
+	return records[:len(records):len(records)]
+}
+
+func (s *Server) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r) {
+		writeError(w, http.StatusUnauthorized, "[API] Invalid API key or access token (unrecognized login or wrong password)")
+		return
+	}
+
+	// strip the API version
+	rest := strings.TrimPrefix(r.URL.Path, adminPrefix)
+	if idx := strings.IndexByte(rest, '/'); idx >= 0 {
+		rest = rest[idx+1:]
+	}
+
+	if f := s.fault(rest); f != nil && applyFault(w, r, f) {
+		return
+	}
+
+	switch {
+	case rest == "graphql.json":
+		s.serveGraphQL(w, r)
+	case strings.HasSuffix(rest, ".json"):
+		if !s.limit(w) {
+			s.serveREST(w, r, strings.TrimSuffix(rest, ".json"))
+		}
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func authorized(r *http.Request) bool {
+	if token := r.Header.Get("X-Shopify-Access-Token"); token != "" {
+		return token == AccessToken
+	}
+
+	user, pass, ok := r.BasicAuth()
+	return ok && user == Username && pass == Password
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"errors": message})
+}
@@ -0,0 +1,277 @@
+package shopifytest_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peterhellberg/link"
+
+	"github.com/demosdemon/shop/pkg/shopify/shopifytest"
+)
+
+const ordersURL = "/admin/api/2020-01/orders.json"
+
+type order struct {
+	ID        int64  `json:"id"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func newServer(t *testing.T, n int) *shopifytest.Server {
+	t.Helper()
+	s := shopifytest.NewServer()
+	t.Cleanup(s.Close)
+
+	epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for id := n; id >= 1; id-- {
+		ts := epoch.Add(time.Duration(id) * time.Minute).Format(time.RFC3339)
+		if err := s.Seed("orders", order{ID: int64(id), UpdatedAt: ts}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func get(t *testing.T, s *shopifytest.Server, rawURL string) (*http.Response, []byte) {
+	t.Helper()
+	if !strings.HasPrefix(rawURL, "http") {
+		rawURL = s.URL + rawURL
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(shopifytest.Username, shopifytest.Password)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, body
+}
+
+func decodeOrders(t *testing.T, body []byte) []int64 {
+	t.Helper()
+	var page struct {
+		Orders []order `json:"orders"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]int64, len(page.Orders))
+	for idx, o := range page.Orders {
+		ids[idx] = o.ID
+	}
+	return ids
+}
+
+func TestPaginatesWithLinkHeader(t *testing.T) {
+	s := newServer(t, 5)
+
+	var ids []int64
+	next := ordersURL + "?limit=2"
+	for pages := 0; next != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination did not end")
+		}
+
+		res, body := get(t, s, next)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d: %s", res.StatusCode, body)
+		}
+		ids = append(ids, decodeOrders(t, body)...)
+
+		next = ""
+		if l, ok := link.ParseResponse(res)["next"]; ok {
+			next = l.URI
+		}
+	}
+
+	// records are listed in id order whatever order they were seeded in
+	want := []int64{1, 2, 3, 4, 5}
+	if len(ids) != len(want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+	for idx := range want {
+		if ids[idx] != want[idx] {
+			t.Fatalf("ids = %v, want %v", ids, want)
+		}
+	}
+}
+
+func TestPageInfoKeepsFilters(t *testing.T) {
+	s := newServer(t, 6)
+
+	res, body := get(t, s, ordersURL+"?limit=2&updated_at_min=2020-01-01T00:03:00Z")
+	if got := decodeOrders(t, body); len(got) != 2 || got[0] != 3 {
+		t.Fatalf("first page = %v, want [3 4]", got)
+	}
+
+	next := link.ParseResponse(res)["next"]
+	if next == nil {
+		t.Fatal("no next link")
+	}
+	_, body = get(t, s, next.URI)
+	if got := decodeOrders(t, body); len(got) != 2 || got[0] != 5 {
+		t.Errorf("second page = %v, want [5 6]", got)
+	}
+
+	// like Shopify, filters cannot be passed along with a cursor
+	u, _ := url.Parse(next.URI)
+	q := u.Query()
+	q.Set("since_id", "1")
+	u.RawQuery = q.Encode()
+	if res, _ := get(t, s, u.String()); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("status with page_info and since_id = %d, want 400", res.StatusCode)
+	}
+}
+
+func TestCount(t *testing.T) {
+	s := newServer(t, 5)
+
+	_, body := get(t, s, "/admin/api/2020-01/orders/count.json?updated_at_max=2020-01-01T00:02:00Z")
+	var count struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(body, &count); err != nil {
+		t.Fatal(err)
+	}
+	if count.Count != 2 {
+		t.Errorf("count = %d, want 2", count.Count)
+	}
+}
+
+func TestRejectsBadCredentials(t *testing.T) {
+	s := newServer(t, 1)
+
+	res, err := http.Get(s.URL + ordersURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", res.StatusCode)
+	}
+}
+
+func TestCallLimit(t *testing.T) {
+	s := newServer(t, 1)
+	s.BucketSize = 2
+	s.LeakRate = 0.5
+
+	for idx := 1; idx <= 2; idx++ {
+		res, _ := get(t, s, ordersURL)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status = %d", idx, res.StatusCode)
+		}
+		if got := res.Header.Get("X-Shopify-Shop-Api-Call-Limit"); !strings.HasSuffix(got, "/2") {
+			t.Errorf("request %d: call limit = %q", idx, got)
+		}
+	}
+
+	res, _ := get(t, s, ordersURL)
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", res.StatusCode)
+	}
+	if got := res.Header.Get("Retry-After"); got != "2.0" {
+		t.Errorf("Retry-After = %q, want 2.0", got)
+	}
+}
+
+func TestInjectedFaults(t *testing.T) {
+	s := newServer(t, 1)
+	s.Inject(shopifytest.Fault{Path: "orders", Status: http.StatusTooManyRequests, RetryAfter: 1500 * time.Millisecond, Count: 1})
+	s.Inject(shopifytest.Fault{Path: "products", Status: http.StatusServiceUnavailable})
+
+	res, _ := get(t, s, ordersURL)
+	if res.StatusCode != http.StatusTooManyRequests || res.Header.Get("Retry-After") != "1.5" {
+		t.Errorf("status = %d, Retry-After = %q", res.StatusCode, res.Header.Get("Retry-After"))
+	}
+
+	// the counted fault is used up, the other one keeps applying
+	if res, _ := get(t, s, ordersURL); res.StatusCode != http.StatusOK {
+		t.Errorf("status after the fault = %d", res.StatusCode)
+	}
+	for idx := 0; idx < 2; idx++ {
+		if res, _ := get(t, s, "/admin/api/2020-01/products.json"); res.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("products status = %d, want 503", res.StatusCode)
+		}
+	}
+
+	s.ClearFaults()
+	if res, _ := get(t, s, "/admin/api/2020-01/products.json"); res.StatusCode != http.StatusOK {
+		t.Errorf("status after ClearFaults = %d", res.StatusCode)
+	}
+	if got := s.Requests(); got != 5 {
+		t.Errorf("Requests() = %d, want 5", got)
+	}
+}
+
+func TestInjectedDelay(t *testing.T) {
+	s := newServer(t, 1)
+	s.Inject(shopifytest.Fault{Path: "orders", Delay: 50 * time.Millisecond, Count: 1})
+
+	start := time.Now()
+	res, _ := get(t, s, ordersURL)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", res.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("responded after %s, want at least 50ms", elapsed)
+	}
+}
+
+func TestInjectedThrottle(t *testing.T) {
+	s := newServer(t, 1)
+	s.Inject(shopifytest.Fault{Path: "graphql.json", Throttled: true, RetryAfter: 2 * time.Second, Count: 1})
+
+	req, err := http.NewRequest(http.MethodPost, s.URL+"/admin/api/2020-01/graphql.json", strings.NewReader(`{"query":"{ shop { name } }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(shopifytest.Username, shopifytest.Password)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	// like Shopify, throttled queries are reported with a 200 status
+	var body struct {
+		Errors []struct {
+			Extensions struct {
+				Code string `json:"code"`
+			} `json:"extensions"`
+		} `json:"errors"`
+		Extensions struct {
+			Cost struct {
+				RequestedQueryCost float64 `json:"requestedQueryCost"`
+				ThrottleStatus     struct {
+					RestoreRate float64 `json:"restoreRate"`
+				} `json:"throttleStatus"`
+			} `json:"cost"`
+		} `json:"extensions"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || len(body.Errors) != 1 || body.Errors[0].Extensions.Code != "THROTTLED" {
+		t.Fatalf("status = %d, errors = %+v", res.StatusCode, body.Errors)
+	}
+	cost := body.Extensions.Cost
+	if wait := cost.RequestedQueryCost / cost.ThrottleStatus.RestoreRate; wait != 2 {
+		t.Errorf("cost restores after %gs, want 2s", wait)
+	}
+}
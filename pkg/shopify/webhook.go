@@ -0,0 +1,59 @@
+package shopify
+
+import (
+	"context"
+	"time"
+)
+
+type WebhookService struct {
+	resourceService
+}
+
+// List returns all webhook subscriptions matching options, following every
+// page.
+func (s *WebhookService) List(ctx context.Context, options interface{}) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := s.list(ctx, options, &webhooks)
+	return webhooks, err
+}
+
+func (s *WebhookService) Get(ctx context.Context, id int64, options interface{}) (*Webhook, error) {
+	webhook := new(Webhook)
+	if err := s.get(ctx, id, options, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Create subscribes to webhook's topic and returns the new subscription.
+func (s *WebhookService) Create(ctx context.Context, webhook Webhook) (*Webhook, error) {
+	rv := new(Webhook)
+	if err := s.create(ctx, webhook, rv); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// Update changes the subscription with webhook's id.
+func (s *WebhookService) Update(ctx context.Context, webhook Webhook) (*Webhook, error) {
+	rv := new(Webhook)
+	if err := s.update(ctx, webhook.ID, webhook, rv); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id int64) error {
+	return s.delete(ctx, id)
+}
+
+type Webhook struct {
+	ID         int64      `json:"id,omitempty"`
+	Address    string     `json:"address,omitempty"`
+	Topic      string     `json:"topic,omitempty"`
+	Format     string     `json:"format,omitempty"`
+	Fields     []string   `json:"fields,omitempty"`
+	APIVersion string     `json:"api_version,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
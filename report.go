@@ -0,0 +1,106 @@
+package main
+
+import (
+	_log "log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/demosdemon/shop/internal/config"
+	"github.com/demosdemon/shop/internal/job"
+	"github.com/demosdemon/shop/pkg/pool"
+	"github.com/demosdemon/shop/pkg/retry"
+)
+
+const (
+	// exitFailure is the exit code of a run in which a store failed to sync.
+	exitFailure = 1
+
+	// exitUnavailable is the exit code of a run in which every store that was
+	// not synced was unavailable.
+	exitUnavailable = 3
+)
+
+// report collects the outcome of every store in a run.
+type report struct {
+	mu          sync.Mutex
+	synced      []string
+	unavailable []job.StoreUnavailableError
+	failed      []storeFailure
+	breakers    []storeBreaker
+	slowest     pool.Result
+}
+
+type storeFailure struct {
+	storeID string
+	err     error
+}
+
+type storeBreaker struct {
+	storeID string
+	state   retry.BreakerState
+	trips   int
+}
+
+func (r *report) add(store *config.Store, breaker *retry.Breaker, result pool.Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := result.Err
+	if result.Duration > r.slowest.Duration {
+		r.slowest = result
+	}
+
+	if breaker != nil && breaker.Trips() > 0 {
+		r.breakers = append(r.breakers, storeBreaker{storeID: store.StoreID, state: breaker.State(), trips: breaker.Trips()})
+	}
+
+	var unavailable job.StoreUnavailableError
+	switch {
+	case err == nil:
+		r.synced = append(r.synced, store.StoreID)
+	case errors.As(err, &unavailable):
+		r.unavailable = append(r.unavailable, unavailable)
+	default:
+		r.failed = append(r.failed, storeFailure{storeID: store.StoreID, err: err})
+	}
+}
+
+func (r *report) print() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.Slice(r.unavailable, func(i, j int) bool { return r.unavailable[i].StoreID < r.unavailable[j].StoreID })
+	sort.Slice(r.failed, func(i, j int) bool { return r.failed[i].storeID < r.failed[j].storeID })
+	sort.Slice(r.breakers, func(i, j int) bool { return r.breakers[i].storeID < r.breakers[j].storeID })
+
+	_log.Printf("%d stores synced, %d unavailable, %d failed", len(r.synced), len(r.unavailable), len(r.failed))
+	if r.slowest.Name != "" {
+		_log.Printf("slowest store %s took %s after waiting %s", r.slowest.Name, r.slowest.Duration.Round(time.Millisecond), r.slowest.Queued.Round(time.Millisecond))
+	}
+	for _, e := range r.unavailable {
+		_log.Printf("* skipped %s: %s (%v)", e.StoreID, e.Condition, e.Err)
+	}
+	for _, f := range r.failed {
+		_log.Printf("* failed %s: %v", f.storeID, f.err)
+	}
+	for _, b := range r.breakers {
+		_log.Printf("* circuit breaker for %s opened %d times, now %s", b.storeID, b.trips, b.state)
+	}
+}
+
+func (r *report) exitCode() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case len(r.failed) > 0:
+		return exitFailure
+	case len(r.unavailable) > 0:
+		return exitUnavailable
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/demosdemon/shop/internal/config"
+	"github.com/demosdemon/shop/internal/job"
+	"github.com/demosdemon/shop/pkg/pool"
+	"github.com/demosdemon/shop/pkg/shopify"
+)
+
+func TestReportExitCode(t *testing.T) {
+	synced := pool.Result{}
+	failed := pool.Result{Err: errors.New("boom")}
+	unavailable := pool.Result{Err: errors.Wrap(job.StoreUnavailableError{
+		StoreID:   "store",
+		Condition: shopify.StoreFrozen,
+		Err:       errors.New("payment required"),
+	}, "orders")}
+
+	tests := []struct {
+		name    string
+		results []pool.Result
+		want    int
+	}{
+		{name: "no stores", want: 0},
+		{name: "all synced", results: []pool.Result{synced, synced}, want: 0},
+		{name: "unavailable", results: []pool.Result{synced, unavailable}, want: exitUnavailable},
+		{name: "failed", results: []pool.Result{synced, failed}, want: exitFailure},
+		{name: "failed and unavailable", results: []pool.Result{unavailable, failed, synced}, want: exitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r report
+			for idx, result := range tt.results {
+				result.Name = fmt.Sprintf("store-%d", idx)
+				result.Duration = time.Duration(idx) * time.Second
+				r.add(&config.Store{StoreID: result.Name}, nil, result)
+			}
+
+			if got := r.exitCode(); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportCountsStores(t *testing.T) {
+	var r report
+	r.add(&config.Store{StoreID: "a"}, nil, pool.Result{Name: "a"})
+	r.add(&config.Store{StoreID: "b"}, nil, pool.Result{Name: "b", Err: errors.New("boom"), Duration: time.Second})
+	r.add(&config.Store{StoreID: "c"}, nil, pool.Result{Name: "c", Err: job.StoreUnavailableError{StoreID: "c", Condition: shopify.StoreLocked}})
+
+	if len(r.synced) != 1 || len(r.failed) != 1 || len(r.unavailable) != 1 {
+		t.Errorf("synced %d, failed %d, unavailable %d; want one each", len(r.synced), len(r.failed), len(r.unavailable))
+	}
+	if r.slowest.Name != "b" {
+		t.Errorf("slowest = %q, want b", r.slowest.Name)
+	}
+}
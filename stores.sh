@@ -9,9 +9,12 @@
                 file: $fn,
                 id: .id,
                 store_id: (.options.name // .options.id),
+                auth_type: (if .options.access_token then "access_token" else "basic" end),
                 username: .options.key,
                 password: .options.password,
-            } | select(.store_id != null)'
+                access_token: .options.access_token,
+                webhook_secret: .options.webhook_secret,
+            } | with_entries(select(.value != null)) | select(.store_id != null)'
   popd > /dev/null || exit 1
 }
 